@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"codegenex/internal/diff"
+	"codegenex/internal/files"
+)
+
+// printDryRun shows what a run would have written: new migrations in full
+// and every other file as a unified diff.
+func printDryRun(changes []files.Change) {
+	for _, change := range changes {
+		if !change.Existed && filepath.Ext(change.Path) == ".sql" {
+			fmt.Printf("=== %s\n%s\n", change.Path, change.After)
+			continue
+		}
+
+		from, to := "a/"+change.Path, "b/"+change.Path
+		if !change.Existed {
+			from = ""
+		}
+		if change.Deleted {
+			to = ""
+		}
+		fmt.Print(diff.Unified(from, to, change.Before, change.After))
+	}
+
+	if len(changes) == 0 {
+		fmt.Println("Dry run: no changes.")
+		return
+	}
+	fmt.Printf("Dry run: %d file(s) would change.\n", len(changes))
+}
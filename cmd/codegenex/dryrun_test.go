@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/generator"
+	"codegenex/internal/parser"
+	"codegenex/internal/types"
+)
+
+// captureStdout returns what fn prints to the standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		output, _ := io.ReadAll(r)
+		done <- string(output)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// dryRun runs an entity action the way main does with --dry-run and returns
+// the changes it would have written.
+func dryRun(t *testing.T, cfg *config.Config, entityName string, action types.Action, args ...string) []files.Change {
+	t.Helper()
+	fields, err := parser.ParseFields(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := files.NewMemory(files.OS)
+	manager := &generator.Manager{Config: cfg, Files: memory}
+	err = manager.GenerateEntity(entityName, action, fields, types.EntityOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return memory.Changes()
+}
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "codegenex.json")
+	err := os.WriteFile(path, []byte(`{}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first runs reach the disk, so the dry run changes their models
+	for _, entityName := range []string{"user", "post"} {
+		for _, change := range dryRun(t, cfg, entityName, types.CreateAction, "name:string") {
+			err = os.MkdirAll(filepath.Dir(change.Path), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(change.Path, change.After, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	userModel := filepath.Join(cfg.ModelDir, "user.go")
+	before, err := os.ReadFile(userModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := dryRun(t, cfg, "post", types.AddFieldsAction, "user_id:int:ref:null")
+	output := captureStdout(t, func() { printDryRun(changes) })
+
+	after, err := os.ReadFile(userModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("dry run wrote the referenced model to disk")
+	}
+	migrations, err := os.ReadDir(cfg.MigrationDir)
+	if err != nil || len(migrations) != 2 {
+		t.Errorf("migrations on disk = %v, %v, want those of the two create runs", migrations, err)
+	}
+
+	wants := []string{
+		// new migrations are printed in full
+		"=== " + cfg.MigrationDir,
+		"ADD COLUMN IF NOT EXISTS user_id INTEGER NULL;",
+		// changed files are diffed
+		"--- a/" + filepath.Join(cfg.ModelDir, "post.go"),
+		// and so is the referenced model that gets its relation field
+		"--- a/" + userModel + "\n+++ b/" + userModel,
+		"+\tPosts     []*Post",
+	}
+	for _, want := range wants {
+		if !strings.Contains(output, want) {
+			t.Errorf("dry run output lacks %q:\n%s", want, output)
+		}
+	}
+	if !strings.HasSuffix(output, fmt.Sprintf("Dry run: %d file(s) would change.\n", len(changes))) {
+		t.Errorf("dry run output does not end with the summary:\n%s", output)
+	}
+
+	output = captureStdout(t, func() { printDryRun(nil) })
+	if output != "Dry run: no changes.\n" {
+		t.Errorf("dry run output without changes = %q", output)
+	}
+}
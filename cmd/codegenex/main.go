@@ -4,29 +4,247 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"codegenex/internal/config"
+	"codegenex/internal/files"
 	"codegenex/internal/generator"
+	"codegenex/internal/lint"
 	"codegenex/internal/parser"
+	"codegenex/internal/types"
 )
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: codegenex <entity_name> <action> [field:type:options ...]")
+	flags, args := parser.ParseFlags(os.Args[1:])
+	if len(args) == 0 || (len(args) < 2 && args[0] != "batch" && args[0] != "lint" && args[0] != "verify" && args[0] != "contract" && args[0] != "squash" && args[0] != "undo") {
+		fmt.Println("Usage: codegenex [--dry-run] [--config=path] [--pk=strategy] [--soft-delete] <entity_name> <action> [field:type:options ...]")
+		fmt.Println("       codegenex [--dry-run] [--config=path] <entity_name> rename_field <old_name:new_name>")
+		fmt.Println("       codegenex [--dry-run] [--config=path] <entity_name> change_type <field:type>")
+		fmt.Println("       codegenex [--dry-run] [--config=path] contract [entity_name]")
+		fmt.Println("       codegenex [--dry-run] [--config=path] batch <spec.json|spec.yaml|spec.toml>")
+		fmt.Println("       codegenex [--dry-run] [--config=path] batch --entity=<name> [--pk=strategy] [--soft-delete] [field:type:options ...] ...")
+		fmt.Println("       codegenex [--dry-run] [--config=path] import <schema.sql>")
+		fmt.Println("       codegenex [--dry-run] [--config=path] state from-models")
+		fmt.Println("       codegenex [--dry-run] [--config=path] state from-migrations [--until=version]")
+		fmt.Println("       codegenex [--dry-run] [--config=path] squash --until=version")
+		fmt.Println("       codegenex [--dry-run] [--config=path] undo [runs]")
+		fmt.Println("       codegenex [--config=path] lint")
+		fmt.Println("       codegenex [--config=path] verify")
 		os.Exit(1)
 	}
 
-	entityName := os.Args[1]
-	action := parser.ParseAction(os.Args[2])
-	fields := parser.ParseFields(os.Args[3:])
-
-	cfg := config.GetConfig()
+	cfg, err := config.Load(flags["config"])
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
 	manager := generator.NewManager(cfg)
 
-	err := manager.GenerateEntity(entityName, action, fields)
-	if err != nil {
-		log.Fatalf("Error generating and saving entity: %v", err)
+	var memory *files.Memory
+	var staged *files.Memory
+	if flags["dry-run"] == "true" {
+		memory = files.NewMemory(files.OS)
+		manager.Files = memory
+	} else if args[0] != "lint" && args[0] != "verify" {
+		// writes are staged and reach the disk together once the run succeeded
+		staged = files.NewMemory(files.OS)
+		manager.Files = staged
+	}
+
+	switch args[0] {
+	case "batch":
+		// groups depend on the position of flags, so read the raw arguments
+		specFile, specs, err := parser.ParseBatch(argsAfter(os.Args[1:], "batch"))
+		if err != nil {
+			log.Fatalf("Error parsing batch: %v", err)
+		}
+		if specFile != "" {
+			specs, err = manager.LoadBatchSpec(specFile)
+			if err != nil {
+				log.Fatalf("Error parsing batch: %v", err)
+			}
+		}
+		err = manager.GenerateBatch(specs)
+		if err != nil {
+			log.Fatalf("Error generating batch: %v", err)
+		}
+		if memory == nil {
+			fmt.Println("Entities created successfully.")
+		}
+	case "lint":
+		findings, err := manager.Lint()
+		if err != nil {
+			log.Fatalf("Error linting migrations: %v", err)
+		}
+		for _, finding := range findings {
+			fmt.Println(finding)
+		}
+		if lint.HasErrors(findings) {
+			os.Exit(1)
+		}
+	case "verify":
+		findings, err := manager.Verify()
+		if err != nil {
+			log.Fatalf("Error verifying migrations: %v", err)
+		}
+		for _, finding := range findings {
+			fmt.Println(finding)
+		}
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+	case "contract":
+		entityName := ""
+		if len(args) > 1 {
+			entityName = args[1]
+		}
+		err = manager.Contract(entityName)
+		if err != nil {
+			log.Fatalf("Error generating contract migrations: %v", err)
+		}
+	case "squash":
+		version, err := untilVersion(flags, args[1:])
+		if err != nil || version <= 0 {
+			fmt.Println("Usage: codegenex squash --until=version")
+			os.Exit(1)
+		}
+		err = manager.Squash(version)
+		if err != nil {
+			log.Fatalf("Error squashing migrations: %v", err)
+		}
+	case "undo":
+		runs := 1
+		if len(args) > 1 {
+			runs, err = strconv.Atoi(args[1])
+			if err != nil || runs < 1 {
+				fmt.Println("Usage: codegenex undo [runs]")
+				os.Exit(1)
+			}
+		}
+		err = manager.Undo(runs)
+		if err != nil {
+			log.Fatalf("Error undoing: %v", err)
+		}
+	case "import":
+		err = manager.ImportSchema(args[1])
+		if err != nil {
+			log.Fatalf("Error importing schema: %v", err)
+		}
+		if memory == nil {
+			fmt.Println("Schema imported successfully.")
+		}
+	case "state":
+		switch args[1] {
+		case "from-models":
+			err = manager.BuildStateFromModels()
+		case "from-migrations":
+			until, parseErr := untilVersion(flags, args[2:])
+			if parseErr != nil {
+				log.Fatalf("Error parsing --until: %v", parseErr)
+			}
+			err = manager.BuildStateFromMigrations(until)
+		default:
+			fmt.Println("Usage: codegenex state from-models|from-migrations [--until=version]")
+			os.Exit(1)
+		}
+		if err != nil {
+			log.Fatalf("Error building state: %v", err)
+		}
+	default:
+		entityName := args[0]
+		action := parser.ParseAction(args[1])
+
+		switch action {
+		case types.SeedAction:
+			if len(args) != 3 {
+				fmt.Println("Usage: codegenex <entity_name> seed <data.csv|data.json>")
+				os.Exit(1)
+			}
+			err = manager.GenerateSeed(entityName, args[2])
+		case types.RenameFieldAction:
+			if len(args) != 3 {
+				fmt.Println("Usage: codegenex <entity_name> rename_field <old_name:new_name>")
+				os.Exit(1)
+			}
+			oldName, newName, parseErr := parser.ParseRename(args[2])
+			if parseErr != nil {
+				log.Fatalf("Error parsing arguments: %v", parseErr)
+			}
+			err = manager.RenameField(entityName, oldName, newName)
+		case types.ChangeTypeAction:
+			if len(args) != 3 {
+				fmt.Println("Usage: codegenex <entity_name> change_type <field:type>")
+				os.Exit(1)
+			}
+			fields, parseErr := parser.ParseFields(args[2:])
+			if parseErr != nil {
+				log.Fatalf("Error parsing arguments: %v", parseErr)
+			}
+			err = manager.ChangeFieldType(entityName, fields[0])
+		default:
+			fields, parseErr := parser.ParseFields(args[2:])
+			if parseErr != nil {
+				log.Fatalf("Error parsing arguments: %v", parseErr)
+			}
+			opts := types.EntityOptions{
+				PrimaryKey: flags["pk"],
+				SoftDelete: flags["soft-delete"] == "true",
+			}
+			err = manager.GenerateEntity(entityName, action, fields, opts)
+		}
+		if err != nil {
+			log.Fatalf("Error generating and saving entity: %v", err)
+		}
+		if memory == nil {
+			fmt.Println("Entity updated successfully.")
+		}
+	}
+
+	if staged != nil {
+		if args[0] != "undo" {
+			err = manager.RecordRun(strings.Join(os.Args[1:], " "), staged.Changes())
+			if err != nil {
+				log.Fatalf("Error writing journal: %v", err)
+			}
+		}
+		err = files.Commit(staged.Changes())
+		if err != nil {
+			log.Fatalf("Error writing files: %v", err)
+		}
 	}
 
-	fmt.Println("Entity updated successfully.")
+	if memory != nil {
+		changes := memory.Changes()
+		printDryRun(changes)
+		if len(changes) > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// argsAfter returns the arguments following the first occurrence of command.
+func argsAfter(args []string, command string) []string {
+	for i, arg := range args {
+		if arg == command {
+			return args[i+1:]
+		}
+	}
+	return nil
+}
+
+// untilVersion reads the --until flag, written as --until=version or as
+// --until version with the version among the remaining arguments. It is 0
+// when the flag is not set.
+func untilVersion(flags map[string]string, args []string) (int64, error) {
+	until, ok := flags["until"]
+	if !ok {
+		return 0, nil
+	}
+	if until == "true" {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("missing version")
+		}
+		until = args[0]
+	}
+	return strconv.ParseInt(until, 10, 64)
 }
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestUntilVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   map[string]string
+		args    []string
+		want    int64
+		wantErr bool
+	}{
+		{name: "not set", flags: map[string]string{}, want: 0},
+		{name: "with equals sign", flags: map[string]string{"until": "20240101120005"}, want: 20240101120005},
+		{name: "as next argument", flags: map[string]string{"until": "true"}, args: []string{"20240101120005"}, want: 20240101120005},
+		{name: "missing version", flags: map[string]string{"until": "true"}, wantErr: true},
+		{name: "not a number", flags: map[string]string{"until": "latest"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := untilVersion(tt.flags, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("untilVersion() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("untilVersion() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
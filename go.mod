@@ -3,6 +3,8 @@ module codegenex
 go 1.23
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/iancoleman/strcase v0.3.0
 	github.com/jinzhu/inflection v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
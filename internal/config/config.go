@@ -1,37 +1,405 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
-	"sync"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
 )
 
 type Config struct {
-	ModelDir     string `json:"model_dir"`
-	MigrationDir string `json:"migration_dir"`
+	ModelDir     string     `json:"model_dir" yaml:"model_dir" toml:"model_dir"`
+	MigrationDir string     `json:"migration_dir" yaml:"migration_dir" toml:"migration_dir"`
+	StateFile    string     `json:"state_file" yaml:"state_file" toml:"state_file"`
+	JournalFile  string     `json:"journal_file" yaml:"journal_file" toml:"journal_file"`
+	TemplateDir  string     `json:"template_dir" yaml:"template_dir" toml:"template_dir"`
+	ModelPackage string     `json:"model_package" yaml:"model_package" toml:"model_package"`
+	PrimaryKey   string     `json:"primary_key" yaml:"primary_key" toml:"primary_key"`
+	SoftDelete   bool       `json:"soft_delete" yaml:"soft_delete" toml:"soft_delete"`
+	Artifacts    []Artifact `json:"artifacts" yaml:"artifacts" toml:"artifacts"`
+
+	// Entities holds per-entity settings keyed by table name.
+	Entities map[string]EntityConfig `json:"entities" yaml:"entities" toml:"entities"`
+	// ConcurrentIndexes builds indexes added to existing tables
+	// concurrently unless a field asks for i=blocking.
+	ConcurrentIndexes bool `json:"concurrent_indexes" yaml:"concurrent_indexes" toml:"concurrent_indexes"`
+	// BackfillBatchSize is the number of rows a backfill updates per
+	// transaction.
+	BackfillBatchSize int `json:"backfill_batch_size" yaml:"backfill_batch_size" toml:"backfill_batch_size"`
+	// ArchiveDir receives the migrations replaced by a squash baseline,
+	// by default the archive directory inside MigrationDir.
+	ArchiveDir string `json:"archive_dir" yaml:"archive_dir" toml:"archive_dir"`
+	// Lint maps lint rules to the severity they are reported with.
+	Lint map[string]string `json:"lint" yaml:"lint" toml:"lint"`
+	// Types defines project field types by name. They take precedence over
+	// the built-in types of the same name.
+	Types map[string]TypeConfig `json:"types" yaml:"types" toml:"types"`
+	// ArrayType is the Go representation of array fields: ArrayGeneric,
+	// ArrayPq or ArrayPgtype.
+	ArrayType string `json:"array_type" yaml:"array_type" toml:"array_type"`
+
+	// Path is the config file the values were loaded from, empty when none
+	// was found.
+	Path string `json:"-" yaml:"-" toml:"-"`
+	// ModulePath and ModuleDir describe the Go module containing ModelDir,
+	// ModelImportPath is the import path of the models package. They are
+	// empty when ModelDir is not inside a module.
+	ModulePath      string `json:"-" yaml:"-" toml:"-"`
+	ModuleDir       string `json:"-" yaml:"-" toml:"-"`
+	ModelImportPath string `json:"-" yaml:"-" toml:"-"`
+}
+
+const (
+	ArtifactSkip      = "skip"
+	ArtifactOverwrite = "overwrite"
+)
+
+// ArtifactActions are the actions artifacts can be generated for.
+var ArtifactActions = []string{"create", "add_fields", "remove_fields", "drop"}
+
+// Artifact is an extra per-entity file rendered from a project template.
+type Artifact struct {
+	Template string   `json:"template" yaml:"template" toml:"template"`
+	Output   string   `json:"output" yaml:"output" toml:"output"`
+	Actions  []string `json:"actions" yaml:"actions" toml:"actions"`
+	IfExists string   `json:"if_exists" yaml:"if_exists" toml:"if_exists"`
+}
+
+// EntityConfig overrides global settings for a single entity.
+type EntityConfig struct {
+	PrimaryKey string `json:"primary_key" yaml:"primary_key" toml:"primary_key"`
+	SoftDelete *bool  `json:"soft_delete" yaml:"soft_delete" toml:"soft_delete"`
 }
 
-var (
-	config     *Config
-	configOnce sync.Once
+const (
+	// ArrayGeneric generates an Array[T] type with Scan and Value into the
+	// model package.
+	ArrayGeneric = "generic"
+	// ArrayPq uses the array types of github.com/lib/pq.
+	ArrayPq = "pq"
+	// ArrayPgtype uses pgtype.FlatArray of github.com/jackc/pgx/v5.
+	ArrayPgtype = "pgtype"
 )
 
-func GetConfig() *Config {
-	configOnce.Do(func() {
-		config = &Config{}
-		file, err := os.Open("codegenex.json")
-		if err == nil {
-			defer file.Close()
-			decoder := json.NewDecoder(file)
-			decoder.Decode(config)
+// Dialect is the SQL dialect migrations are generated for.
+const Dialect = "postgres"
+
+// TypeConfig maps a project field type onto a column and a model field.
+// Check and Setup are templates executed with the .Table and .Column of the
+// field.
+type TypeConfig struct {
+	// SQL is the column type per dialect, e.g. {"postgres": "NUMERIC(19,4)"}.
+	SQL map[string]string `json:"sql" yaml:"sql" toml:"sql"`
+	// Go is the type of the model field and Import the package it needs.
+	Go     string `json:"go" yaml:"go" toml:"go"`
+	Import string `json:"import" yaml:"import" toml:"import"`
+	// Default is the column default of fields that do not set their own.
+	Default string `json:"default" yaml:"default" toml:"default"`
+	// Check is the expression of a CHECK constraint on the column.
+	Check string `json:"check" yaml:"check" toml:"check"`
+	// Setup is SQL the Up section runs before adding a column of the type,
+	// e.g. CREATE EXTENSION IF NOT EXISTS citext.
+	Setup string `json:"setup" yaml:"setup" toml:"setup"`
+}
+
+// SnippetData is what the Check and Setup templates of a type see.
+type SnippetData struct {
+	Table  string
+	Column string
+}
+
+// RenderSnippet executes a Check or Setup template of a type.
+func RenderSnippet(snippet string, data SnippetData) (string, error) {
+	tmpl, err := template.New("snippet").Option("missingkey=error").Parse(snippet)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// SQLType returns the column type of the type in the generated dialect.
+func (t TypeConfig) SQLType() string {
+	return t.SQL[Dialect]
+}
+
+// FileNames are the config files looked up in every directory, in order.
+var FileNames = []string{"codegenex.json", "codegenex.yaml", "codegenex.yml", "codegenex.toml"}
+
+const envPrefix = "CODEGENEX_"
+
+// Load reads the config file at path, or when path is empty the nearest
+// config file found by walking up from the working directory, the same way
+// go finds go.mod. Relative paths in the config are resolved against the
+// config directory. CODEGENEX_* environment variables override scalar keys.
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	if path == "" {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+	if path == "" {
+		found, err := discover()
+		if err != nil {
+			return nil, err
 		}
+		path = found
+	}
 
-		if config.ModelDir == "" {
-			config.ModelDir = "_gen/models"
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading config %s: %w", path, err)
+		}
+		err = decode(path, content, cfg)
+		if err != nil {
+			return nil, err
 		}
-		if config.MigrationDir == "" {
-			config.MigrationDir = "_gen/migrations"
+		err = cfg.validateArtifacts(path, content)
+		if err != nil {
+			return nil, err
 		}
-	})
-	return config
+		cfg.Path = path
+	}
+
+	if cfg.ModelDir == "" {
+		cfg.ModelDir = "_gen/models"
+	}
+	if cfg.MigrationDir == "" {
+		cfg.MigrationDir = "_gen/migrations"
+	}
+	if cfg.StateFile == "" {
+		cfg.StateFile = "codegenex.state.json"
+	}
+	if cfg.JournalFile == "" {
+		cfg.JournalFile = "codegenex.journal.json"
+	}
+	if cfg.PrimaryKey == "" {
+		cfg.PrimaryKey = "serial"
+	}
+	if cfg.BackfillBatchSize <= 0 {
+		cfg.BackfillBatchSize = 1000
+	}
+	if cfg.ArrayType == "" {
+		cfg.ArrayType = ArrayGeneric
+	}
+
+	if cfg.Path != "" {
+		cfg.resolvePaths(filepath.Dir(cfg.Path))
+	}
+
+	// environment values are taken as is, relative to the working directory
+	err := applyEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.ArchiveDir == "" {
+		cfg.ArchiveDir = filepath.Join(cfg.MigrationDir, "archive")
+	}
+
+	err = cfg.resolveModelPackage()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.validateTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.ArrayType {
+	case ArrayGeneric, ArrayPq, ArrayPgtype:
+	default:
+		return nil, fmt.Errorf("unknown array_type %q, expected %s, %s or %s", cfg.ArrayType, ArrayGeneric, ArrayPq, ArrayPgtype)
+	}
+
+	return cfg, nil
+}
+
+// discover walks up from the working directory and returns the first config
+// file found, or an empty string when there is none.
+func discover() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("error getting working directory: %w", err)
+	}
+
+	for {
+		found := make([]string, 0, 1)
+		for _, name := range FileNames {
+			candidate := filepath.Join(dir, name)
+			_, err := os.Stat(candidate)
+			if err == nil {
+				found = append(found, candidate)
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("error checking config %s: %w", candidate, err)
+			}
+		}
+		if len(found) > 1 {
+			return "", fmt.Errorf("multiple config files found: %s", strings.Join(found, ", "))
+		}
+		if len(found) == 1 {
+			return relativeToWorkDir(found[0]), nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// applyEnv overrides scalar top level keys with CODEGENEX_<KEY> variables,
+// e.g. CODEGENEX_MODEL_DIR for model_dir.
+func applyEnv(cfg *Config) error {
+	value := reflect.ValueOf(cfg).Elem()
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		env := envPrefix + strings.ToUpper(key)
+		raw, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+
+		field := value.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(raw)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("invalid value %q of %s: expected a boolean", raw, env)
+			}
+			field.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("invalid value %q of %s: expected an integer", raw, env)
+			}
+			field.SetInt(int64(n))
+		default:
+			return fmt.Errorf("%s cannot be set from the environment", env)
+		}
+	}
+	return nil
+}
+
+// EntityPrimaryKey returns the primary key strategy configured for the
+// table, falling back to the global one.
+func (c *Config) EntityPrimaryKey(table string) string {
+	if entity, ok := c.Entities[table]; ok && entity.PrimaryKey != "" {
+		return entity.PrimaryKey
+	}
+	return c.PrimaryKey
+}
+
+// EntitySoftDelete reports whether new tables of the entity use soft
+// deletes, falling back to the global setting.
+func (c *Config) EntitySoftDelete(table string) bool {
+	if entity, ok := c.Entities[table]; ok && entity.SoftDelete != nil {
+		return *entity.SoftDelete
+	}
+	return c.SoftDelete
+}
+
+// validateTypes checks that every project type maps onto the generated
+// dialect and a Go type and that its templates execute.
+func (c *Config) validateTypes() error {
+	for name, t := range c.Types {
+		switch {
+		case name == "" || strings.ContainsAny(name, ":[]() "):
+			return fmt.Errorf("invalid type name %q", name)
+		case t.SQLType() == "":
+			return fmt.Errorf("type %s has no sql type for %s", name, Dialect)
+		case t.Go == "":
+			return fmt.Errorf("type %s has no go type", name)
+		case strings.Contains(t.Go, ".") && t.Import == "":
+			return fmt.Errorf("type %s needs the import of %s", name, t.Go)
+		}
+
+		sample := SnippetData{Table: "table", Column: "column"}
+		for key, snippet := range map[string]string{"check": t.Check, "setup": t.Setup} {
+			_, err := RenderSnippet(snippet, sample)
+			if err != nil {
+				return fmt.Errorf("invalid %s of type %s: %w", key, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateArtifacts rejects unknown if_exists values and actions of the
+// artifacts, reported at the line they are written on.
+func (c *Config) validateArtifacts(path string, content []byte) error {
+	for _, artifact := range c.Artifacts {
+		switch artifact.IfExists {
+		case "", ArtifactSkip, ArtifactOverwrite:
+		default:
+			msg := fmt.Sprintf("unknown if_exists %q of artifact %s, expected %s or %s", artifact.IfExists, artifact.Template, ArtifactSkip, ArtifactOverwrite)
+			return valueError(path, content, artifact.IfExists, msg)
+		}
+		for _, action := range artifact.Actions {
+			if !slices.Contains(ArtifactActions, action) {
+				msg := fmt.Sprintf("unknown action %q of artifact %s, expected one of %s", action, artifact.Template, strings.Join(ArtifactActions, ", "))
+				return valueError(path, content, action, msg)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolvePaths(dir string) {
+	c.ModelDir = resolvePath(dir, c.ModelDir)
+	c.MigrationDir = resolvePath(dir, c.MigrationDir)
+	c.StateFile = resolvePath(dir, c.StateFile)
+	c.JournalFile = resolvePath(dir, c.JournalFile)
+	if c.TemplateDir != "" {
+		c.TemplateDir = resolvePath(dir, c.TemplateDir)
+	}
+	c.ArchiveDir = resolvePath(dir, c.ArchiveDir)
+	for i := range c.Artifacts {
+		c.Artifacts[i].Template = resolvePath(dir, c.Artifacts[i].Template)
+		c.Artifacts[i].Output = resolvePath(dir, c.Artifacts[i].Output)
+	}
+}
+
+// resolvePath joins a path from the config with the config directory and
+// returns it relative to the working directory when possible.
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return relativeToWorkDir(filepath.Join(dir, path))
+}
+
+func relativeToWorkDir(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, abs)
+	if err != nil {
+		return path
+	}
+	return rel
 }
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes the config file into an empty directory and returns its
+// path.
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{
+			name:    "json unknown key",
+			file:    "codegenex.json",
+			content: "{\n  \"model_dir\": \"models\",\n  \"foo\": 1\n}",
+			want:    "codegenex.json:3:3: unknown key \"foo\"",
+		},
+		{
+			name:    "json nested unknown key",
+			file:    "codegenex.json",
+			content: "{\n  \"entities\": {\n    \"users\": {\"primary_key\": \"uuid\", \"pk\": \"uuid\"}\n  }\n}",
+			want:    "codegenex.json:3:38: unknown key \"pk\"",
+		},
+		{
+			name:    "json syntax",
+			file:    "codegenex.json",
+			content: "{\n  \"model_dir\": \"models\"\n  \"migration_dir\": \"migrations\"\n}",
+			want:    "codegenex.json:3:3: invalid character '\"' after object key:value pair",
+		},
+		{
+			name:    "json type",
+			file:    "codegenex.json",
+			content: "{\n  \"soft_delete\": \"yes\"\n}",
+			want:    "codegenex.json:2:23: invalid value for soft_delete: expected bool, found string",
+		},
+		{
+			name:    "yaml unknown key",
+			file:    "codegenex.yaml",
+			content: "model_dir: models\nfoo: 1\n",
+			want:    "codegenex.yaml:2: unknown key \"foo\"",
+		},
+		{
+			name:    "yaml syntax",
+			file:    "codegenex.yml",
+			content: "model_dir: models\n  migration_dir: [\n",
+			want:    "codegenex.yml:2:",
+		},
+		{
+			name:    "toml unknown key",
+			file:    "codegenex.toml",
+			content: "model_dir = \"models\"\n\n[entities.users]\nprimary_key = \"uuid\"\npk = \"uuid\"\n",
+			want:    "codegenex.toml:5: unknown key \"entities.users.pk\"",
+		},
+		{
+			name:    "toml syntax",
+			file:    "codegenex.toml",
+			content: "model_dir = \"models\"\nmigration_dir = \n",
+			want:    "codegenex.toml:2:",
+		},
+		{
+			name:    "unsupported format",
+			file:    "codegenex.ini",
+			content: "model_dir = models",
+			want:    "unsupported config format:",
+		},
+		{
+			name:    "artifact if_exists",
+			file:    "codegenex.json",
+			content: "{\n  \"artifacts\": [\n    {\"template\": \"service.tmpl\", \"output\": \"service.go\", \"if_exists\": \"replace\"}\n  ]\n}",
+			want:    "codegenex.json:3: unknown if_exists \"replace\" of artifact service.tmpl, expected skip or overwrite",
+		},
+		{
+			name:    "artifact action",
+			file:    "codegenex.yaml",
+			content: "artifacts:\n  - template: service.tmpl\n    output: service.go\n    actions:\n      - create\n      - delete\n",
+			want:    "codegenex.yaml:6: unknown action \"delete\" of artifact service.tmpl, expected one of create, add_fields, remove_fields, drop",
+		},
+		{
+			name:    "type name",
+			file:    "codegenex.json",
+			content: `{"types": {"money[]": {"sql": {"postgres": "NUMERIC"}, "go": "string"}}}`,
+			want:    "invalid type name \"money[]\"",
+		},
+		{
+			name:    "type without postgres",
+			file:    "codegenex.json",
+			content: `{"types": {"money": {"sql": {"mysql": "DECIMAL"}, "go": "string"}}}`,
+			want:    "type money has no sql type for postgres",
+		},
+		{
+			name:    "type without go",
+			file:    "codegenex.yaml",
+			content: "types:\n  money:\n    sql:\n      postgres: NUMERIC\n",
+			want:    "type money has no go type",
+		},
+		{
+			name:    "type without import",
+			file:    "codegenex.json",
+			content: `{"types": {"money": {"sql": {"postgres": "NUMERIC"}, "go": "decimal.Decimal"}}}`,
+			want:    "type money needs the import of decimal.Decimal",
+		},
+		{
+			name:    "type check template",
+			file:    "codegenex.json",
+			content: `{"types": {"email": {"sql": {"postgres": "TEXT"}, "go": "string", "check": "{{.Col}} <> ''"}}}`,
+			want:    "invalid check of type email:",
+		},
+		{
+			name:    "array type",
+			file:    "codegenex.json",
+			content: "{\"array_type\": \"slice\"}",
+			want:    "unknown array_type \"slice\", expected generic, pq or pgtype",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfig(t, tt.file, tt.content)
+			_, err := Load(path)
+			if err == nil {
+				t.Fatalf("Load() succeeded, want error %q", tt.want)
+			}
+			got := strings.TrimPrefix(err.Error(), filepath.Dir(path)+string(filepath.Separator))
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("error = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+	}{
+		{file: "codegenex.json", content: `{"model_dir": "models", "primary_key": "uuid", "entities": {"users": {"soft_delete": true}}}`},
+		{file: "codegenex.yaml", content: "model_dir: models\nprimary_key: uuid\nentities:\n  users:\n    soft_delete: true\n"},
+		{file: "codegenex.toml", content: "model_dir = \"models\"\nprimary_key = \"uuid\"\n\n[entities.users]\nsoft_delete = true\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := writeConfig(t, tt.file, tt.content)
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			modelDir, err := filepath.Abs(cfg.ModelDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if modelDir != filepath.Join(filepath.Dir(path), "models") {
+				t.Errorf("model_dir = %q, want models next to the config", cfg.ModelDir)
+			}
+			if cfg.EntityPrimaryKey("users") != "uuid" || !cfg.EntitySoftDelete("users") {
+				t.Errorf("users = %s soft delete %v, want uuid with soft delete", cfg.EntityPrimaryKey("users"), cfg.EntitySoftDelete("users"))
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// decode parses the config in the format given by the file extension.
+// Malformed content and unknown keys are reported as path:line:col errors.
+func decode(path string, content []byte, cfg *Config) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return decodeJSON(path, content, cfg)
+	case ".yaml", ".yml":
+		return decodeYAML(path, content, cfg)
+	case ".toml":
+		return decodeTOML(path, content, cfg)
+	default:
+		return fmt.Errorf("unsupported config format: %s", path)
+	}
+}
+
+func decodeJSON(path string, content []byte, cfg *Config) error {
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil
+	}
+
+	err := json.Unmarshal(content, cfg)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			// the offset counts the offending byte as read
+			return positionError(path, content, syntaxErr.Offset-1, strings.TrimPrefix(syntaxErr.Error(), "json: "))
+		case errors.As(err, &typeErr):
+			msg := fmt.Sprintf("invalid value for %s: expected %s, found %s", typeErr.Field, typeErr.Type, typeErr.Value)
+			return positionError(path, content, typeErr.Offset, msg)
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	return checkJSONKeys(path, content, decoder, reflect.TypeOf(cfg))
+}
+
+// checkJSONKeys walks the JSON tokens alongside the Go type and reports the
+// first object key that has no matching field.
+func checkJSONKeys(path string, content []byte, decoder *json.Decoder, t reflect.Type) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	tok, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		for decoder.More() {
+			offset := skipSeparators(content, decoder.InputOffset())
+			keyTok, err := decoder.Token()
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			key := keyTok.(string)
+
+			var fieldType reflect.Type
+			switch {
+			case t == nil || t.Kind() == reflect.Interface:
+			case t.Kind() == reflect.Map:
+				fieldType = t.Elem()
+			case t.Kind() == reflect.Struct:
+				field, ok := fieldByTag(t, "json", key)
+				if !ok {
+					return positionError(path, content, offset, fmt.Sprintf("unknown key %q", key))
+				}
+				fieldType = field.Type
+			}
+
+			err = checkJSONKeys(path, content, decoder, fieldType)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	case json.Delim('['):
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+		for decoder.More() {
+			err = checkJSONKeys(path, content, decoder, elemType)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// skipSeparators moves the offset past whitespace and commas to the start of
+// the next token.
+func skipSeparators(content []byte, offset int64) int64 {
+	for offset < int64(len(content)) {
+		switch content[offset] {
+		case ' ', '\t', '\r', '\n', ',':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func fieldByTag(t reflect.Type, tagName, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if strings.Split(field.Tag.Get(tagName), ",")[0] == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+var (
+	yamlLinePattern    = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	yamlUnknownPattern = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+)
+
+func decodeYAML(path string, content []byte, cfg *Config) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(cfg)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages := make([]string, 0, len(typeErr.Errors))
+		for _, msg := range typeErr.Errors {
+			messages = append(messages, yamlMessage(path, msg))
+		}
+		return errors.New(strings.Join(messages, "\n"))
+	}
+	return errors.New(yamlMessage(path, err.Error()))
+}
+
+// yamlMessage rewrites "line N: msg" errors of the yaml package into the
+// path:line: form used for all config formats.
+func yamlMessage(path, msg string) string {
+	match := yamlLinePattern.FindStringSubmatch(msg)
+	if match == nil {
+		return fmt.Sprintf("%s: %s", path, strings.TrimPrefix(msg, "yaml: "))
+	}
+	text := match[2]
+	if unknown := yamlUnknownPattern.FindStringSubmatch(text); unknown != nil {
+		text = fmt.Sprintf("unknown key %q", unknown[1])
+	}
+	return fmt.Sprintf("%s:%s: %s", path, match[1], text)
+}
+
+func decodeTOML(path string, content []byte, cfg *Config) error {
+	meta, err := toml.Decode(string(content), cfg)
+	if err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			return fmt.Errorf("%s:%d:%d: %s", path, parseErr.Position.Line, parseErr.Position.Col, parseErr.Message)
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	undecoded := meta.Undecoded()
+	if len(undecoded) == 0 {
+		return nil
+	}
+	key := undecoded[0]
+	if line := tomlKeyLine(content, key[len(key)-1]); line > 0 {
+		return fmt.Errorf("%s:%d: unknown key %q", path, line, key.String())
+	}
+	return fmt.Errorf("%s: unknown key %q", path, key.String())
+}
+
+// tomlKeyLine finds the line that defines the key or table; the toml package
+// does not expose key positions.
+func tomlKeyLine(content []byte, name string) int {
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			table := strings.Trim(line, "[] ")
+			if table == name || strings.HasSuffix(table, "."+name) {
+				return i + 1
+			}
+			continue
+		}
+		if key, _, found := strings.Cut(line, "="); found && strings.Trim(strings.TrimSpace(key), `"'`) == name {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func positionError(path string, content []byte, offset int64, msg string) error {
+	line, col := 1, 1
+	for i := int64(0); i < offset && i < int64(len(content)); i++ {
+		if content[i] == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("%s:%d:%d: %s", path, line, col, msg)
+}
+
+// valueError reports an invalid config value at the first line the value is
+// written on, or at the file when it is not found.
+func valueError(path string, content []byte, value, msg string) error {
+	pattern := regexp.MustCompile(`(^|\W)` + regexp.QuoteMeta(value) + `(\W|$)`)
+	for i, line := range strings.Split(string(content), "\n") {
+		if pattern.MatchString(line) {
+			return fmt.Errorf("%s:%d: %s", path, i+1, msg)
+		}
+	}
+	return fmt.Errorf("%s: %s", path, msg)
+}
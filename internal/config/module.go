@@ -0,0 +1,134 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// resolveModelPackage fills in the package name of the model directory and,
+// when the directory is inside a Go module, its full import path.
+func (c *Config) resolveModelPackage() error {
+	if c.ModelPackage == "" {
+		c.ModelPackage = existingPackage(c.ModelDir)
+	}
+	if c.ModelPackage == "" {
+		c.ModelPackage = packageNameFromDir(c.ModelDir)
+	}
+
+	modulePath, moduleDir, err := findModule(c.ModelDir)
+	if err != nil {
+		return err
+	}
+	if modulePath == "" {
+		return nil
+	}
+	c.ModulePath = modulePath
+	c.ModuleDir = relativeToWorkDir(moduleDir)
+
+	absModelDir, err := filepath.Abs(c.ModelDir)
+	if err != nil {
+		return fmt.Errorf("error resolving model directory %s: %w", c.ModelDir, err)
+	}
+	rel, err := filepath.Rel(moduleDir, absModelDir)
+	if err != nil {
+		return fmt.Errorf("error resolving model directory %s: %w", c.ModelDir, err)
+	}
+	c.ModelImportPath = path.Join(modulePath, filepath.ToSlash(rel))
+
+	return nil
+}
+
+// existingPackage returns the package declared by the Go files already in
+// dir, so new models join it instead of introducing a second package.
+func existingPackage(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, name), nil, parser.PackageClauseOnly)
+		if err == nil {
+			return file.Name.Name
+		}
+	}
+	return ""
+}
+
+// packageNameFromDir turns the last element of dir into a valid package
+// name, e.g. "_gen/models" becomes "models".
+func packageNameFromDir(dir string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(filepath.Base(filepath.Clean(dir))) {
+		if r >= 'a' && r <= 'z' || r == '_' || r >= '0' && r <= '9' && sb.Len() > 0 {
+			sb.WriteRune(r)
+		}
+	}
+	name := strings.Trim(sb.String(), "_")
+	if name == "" {
+		return "model"
+	}
+	return name
+}
+
+// findModule walks up from dir to the nearest go.mod and returns the module
+// path and the absolute module root. Both are empty when there is no go.mod.
+func findModule(dir string) (string, string, error) {
+	current, err := filepath.Abs(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("error resolving directory %s: %w", dir, err)
+	}
+
+	for {
+		goMod := filepath.Join(current, "go.mod")
+		content, err := os.ReadFile(goMod)
+		if err == nil {
+			modulePath := parseModulePath(content)
+			if modulePath == "" {
+				return "", "", fmt.Errorf("%s: missing module directive", goMod)
+			}
+			return modulePath, current, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", fmt.Errorf("error reading %s: %w", goMod, err)
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			return "", "", nil
+		}
+		current = parent
+	}
+}
+
+func parseModulePath(content []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = strings.TrimSpace(line[:comment])
+		}
+		if !strings.HasPrefix(line, "module") {
+			continue
+		}
+		modulePath := strings.TrimSpace(strings.TrimPrefix(line, "module"))
+		if unquoted, err := strconv.Unquote(modulePath); err == nil {
+			modulePath = unquoted
+		}
+		return modulePath
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadModelPackage(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are written next to the config, config.json holds the config
+		files           map[string]string
+		config          string
+		wantPackage     string
+		wantModule      string
+		wantImportPath  string
+		wantErrContains string
+	}{
+		{
+			name:        "outside a module",
+			files:       map[string]string{"app/codegenex.json": `{}`},
+			config:      "app/codegenex.json",
+			wantPackage: "models",
+		},
+		{
+			name: "module root",
+			files: map[string]string{
+				"app/go.mod":         "module example.com/app\n\ngo 1.23\n",
+				"app/codegenex.json": `{"model_dir": "internal/models"}`,
+			},
+			config:         "app/codegenex.json",
+			wantPackage:    "models",
+			wantModule:     "example.com/app",
+			wantImportPath: "example.com/app/internal/models",
+		},
+		{
+			name: "config below the module root",
+			files: map[string]string{
+				"app/go.mod":                   "// the app\nmodule \"example.com/app\" // quoted\n",
+				"app/tools/gen/codegenex.json": `{"model_dir": "../../store"}`,
+			},
+			config:         "app/tools/gen/codegenex.json",
+			wantPackage:    "store",
+			wantModule:     "example.com/app",
+			wantImportPath: "example.com/app/store",
+		},
+		{
+			name: "package of existing files",
+			files: map[string]string{
+				"app/go.mod":            "module example.com/app\n",
+				"app/models/db.go":      "package entity\n",
+				"app/models/db_test.go": "package entity_test\n",
+				"app/codegenex.json":    `{"model_dir": "models"}`,
+			},
+			config:         "app/codegenex.json",
+			wantPackage:    "entity",
+			wantModule:     "example.com/app",
+			wantImportPath: "example.com/app/models",
+		},
+		{
+			name: "configured package",
+			files: map[string]string{
+				"app/go.mod":         "module example.com/app\n",
+				"app/codegenex.json": `{"model_dir": "_gen/models", "model_package": "model"}`,
+			},
+			config:         "app/codegenex.json",
+			wantPackage:    "model",
+			wantModule:     "example.com/app",
+			wantImportPath: "example.com/app/_gen/models",
+		},
+		{
+			name: "go.mod without module directive",
+			files: map[string]string{
+				"app/go.mod":         "go 1.23\n",
+				"app/codegenex.json": `{}`,
+			},
+			config:          "app/codegenex.json",
+			wantErrContains: "go.mod: missing module directive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range tt.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				err := os.MkdirAll(filepath.Dir(path), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = os.WriteFile(path, []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg, err := Load(filepath.Join(dir, filepath.FromSlash(tt.config)))
+			if tt.wantErrContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrContains) {
+					t.Fatalf("Load() error = %v, want it to contain %q", err, tt.wantErrContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ModelPackage != tt.wantPackage || cfg.ModulePath != tt.wantModule || cfg.ModelImportPath != tt.wantImportPath {
+				t.Errorf("package, module and import path = %q, %q, %q, want %q, %q, %q",
+					cfg.ModelPackage, cfg.ModulePath, cfg.ModelImportPath, tt.wantPackage, tt.wantModule, tt.wantImportPath)
+			}
+		})
+	}
+}
+
+func TestPackageNameFromDir(t *testing.T) {
+	tests := map[string]string{
+		"_gen/models":   "models",
+		"internal/Repo": "repo",
+		"3d-models":     "dmodels",
+		"v2":            "v2",
+		"api_v2":        "api_v2",
+		"_gen/__":       "model",
+		".":             "model",
+	}
+	for dir, want := range tests {
+		got := packageNameFromDir(dir)
+		if got != want {
+			t.Errorf("packageNameFromDir(%q) = %q, want %q", dir, got, want)
+		}
+	}
+}
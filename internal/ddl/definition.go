@@ -0,0 +1,82 @@
+package ddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Definition renders the column as written in CREATE TABLE. A foreign key
+// declared on the column is left out, see Table.ForeignKeys.
+func (c *Column) Definition() string {
+	definition := c.Name + " " + c.Type
+	if c.Generated != "" {
+		definition += " " + c.Generated
+	}
+	if c.NotNull && !c.PrimaryKey {
+		definition += " NOT NULL"
+	}
+	if c.Default != "" {
+		definition += " DEFAULT " + c.Default
+	}
+	if c.PrimaryKey {
+		definition += " PRIMARY KEY"
+	}
+	if c.Unique {
+		definition += " UNIQUE"
+	}
+	return definition
+}
+
+// Definition renders the constraint without its name, as written after
+// CONSTRAINT name.
+func (c *Constraint) Definition() string {
+	switch c.Kind {
+	case PrimaryKeyConstraint:
+		return fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(c.Columns, ", "))
+	case UniqueConstraint:
+		return fmt.Sprintf("UNIQUE (%s)", strings.Join(c.Columns, ", "))
+	case ForeignKeyConstraint:
+		return fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s(%s) ON DELETE %s",
+			strings.Join(c.Columns, ", "), c.RefTable, strings.Join(c.RefColumns, ", "), c.OnDelete)
+	case CheckConstraint:
+		return fmt.Sprintf("CHECK (%s)", c.Check)
+	}
+	return ""
+}
+
+// Definition renders the CREATE INDEX statement of the index.
+func (i *Index) Definition() string {
+	definition := "CREATE INDEX"
+	if i.Unique {
+		definition = "CREATE UNIQUE INDEX"
+	}
+	if i.Name != "" {
+		definition += " " + i.Name
+	}
+	definition += " ON " + i.Table
+	if i.Method != "" {
+		definition += " USING " + i.Method
+	}
+	definition += fmt.Sprintf(" (%s)", strings.Join(i.Columns, ", "))
+	if i.Where != "" {
+		definition += " WHERE " + i.Where
+	}
+	return definition
+}
+
+// Definition renders the CREATE TRIGGER statement of the trigger.
+func (t *Trigger) Definition() string {
+	return fmt.Sprintf("CREATE TRIGGER %s %s ON %s %s", t.Name, t.Timing, t.Table, t.Action)
+}
+
+// ForeignKeys returns the foreign keys of the table, declared inline or as
+// table constraints, with the names Postgres gives unnamed ones.
+func (t *Table) ForeignKeys() []*Constraint {
+	foreignKeys := make([]*Constraint, 0)
+	for _, constraint := range t.AllConstraints() {
+		if constraint.Kind == ForeignKeyConstraint {
+			foreignKeys = append(foreignKeys, constraint)
+		}
+	}
+	return foreignKeys
+}
@@ -0,0 +1,173 @@
+package ddl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Clone returns a deep copy of the schema.
+func (s *Schema) Clone() *Schema {
+	clone := &Schema{
+		Tables:    make([]*Table, 0, len(s.Tables)),
+		Enums:     make([]*Enum, 0, len(s.Enums)),
+		Indexes:   make([]*Index, 0, len(s.Indexes)),
+		Triggers:  make([]*Trigger, 0, len(s.Triggers)),
+		Functions: make([]*Function, 0, len(s.Functions)),
+	}
+	for _, table := range s.Tables {
+		t := &Table{Name: table.Name}
+		for _, column := range table.Columns {
+			c := *column
+			if column.References != nil {
+				c.References = cloneConstraint(column.References)
+			}
+			t.Columns = append(t.Columns, &c)
+		}
+		for _, constraint := range table.Constraints {
+			t.Constraints = append(t.Constraints, cloneConstraint(constraint))
+		}
+		clone.Tables = append(clone.Tables, t)
+	}
+	for _, enum := range s.Enums {
+		clone.Enums = append(clone.Enums, &Enum{Name: enum.Name, Values: append([]string(nil), enum.Values...)})
+	}
+	for _, index := range s.Indexes {
+		i := *index
+		i.Columns = append([]string(nil), index.Columns...)
+		clone.Indexes = append(clone.Indexes, &i)
+	}
+	for _, trigger := range s.Triggers {
+		t := *trigger
+		clone.Triggers = append(clone.Triggers, &t)
+	}
+	for _, function := range s.Functions {
+		f := *function
+		clone.Functions = append(clone.Functions, &f)
+	}
+	return clone
+}
+
+func cloneConstraint(constraint *Constraint) *Constraint {
+	c := *constraint
+	c.Columns = append([]string(nil), constraint.Columns...)
+	c.RefColumns = append([]string(nil), constraint.RefColumns...)
+	return &c
+}
+
+// Diff describes how actual differs from expected, one message per
+// object. Column order is not compared since a column added back ends up
+// last in Postgres as well. Functions are not compared either: generated
+// migrations share the trigger function and never drop it.
+func Diff(expected, actual *Schema) []string {
+	diffs := make([]string, 0)
+
+	diffs = append(diffs, diffObjects("table", tableDefinitions(expected), tableDefinitions(actual))...)
+	for _, table := range expected.Tables {
+		other := actual.Table(table.Name)
+		if other == nil {
+			continue
+		}
+		diffs = append(diffs, diffObjects("column", columnDefinitions(table), columnDefinitions(other))...)
+		diffs = append(diffs, diffObjects("constraint", constraintDefinitions(table), constraintDefinitions(other))...)
+	}
+	diffs = append(diffs, diffObjects("index", indexDefinitions(expected), indexDefinitions(actual))...)
+	diffs = append(diffs, diffObjects("type", enumDefinitions(expected), enumDefinitions(actual))...)
+	diffs = append(diffs, diffObjects("trigger", triggerDefinitions(expected), triggerDefinitions(actual))...)
+
+	return diffs
+}
+
+// diffObjects compares objects of one kind given as name to definition
+// maps.
+func diffObjects(kind string, expected, actual map[string]string) []string {
+	names := make([]string, 0, len(expected)+len(actual))
+	for name := range expected {
+		names = append(names, name)
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	diffs := make([]string, 0)
+	for _, name := range names {
+		want, inExpected := expected[name]
+		got, inActual := actual[name]
+		switch {
+		case !inActual:
+			diffs = append(diffs, fmt.Sprintf("%s %s is missing", kind, name))
+		case !inExpected:
+			diffs = append(diffs, fmt.Sprintf("%s %s is left over", kind, name))
+		case want != got:
+			diffs = append(diffs, fmt.Sprintf("%s %s is %s, expected %s", kind, name, got, want))
+		}
+	}
+	return diffs
+}
+
+func tableDefinitions(s *Schema) map[string]string {
+	definitions := make(map[string]string, len(s.Tables))
+	for _, table := range s.Tables {
+		definitions[table.Name] = ""
+	}
+	return definitions
+}
+
+func columnDefinitions(table *Table) map[string]string {
+	definitions := make(map[string]string, len(table.Columns))
+	for _, column := range table.Columns {
+		definition := column.Type
+		if column.NotNull {
+			definition += " NOT NULL"
+		}
+		if column.Default != "" {
+			definition += " DEFAULT " + column.Default
+		}
+		definitions[table.Name+"."+column.Name] = definition
+	}
+	return definitions
+}
+
+func constraintDefinitions(table *Table) map[string]string {
+	constraints := table.AllConstraints()
+	definitions := make(map[string]string, len(constraints))
+	for _, constraint := range constraints {
+		definitions[table.Name+"."+constraint.Name] = constraint.Definition()
+	}
+	return definitions
+}
+
+func indexDefinitions(s *Schema) map[string]string {
+	definitions := make(map[string]string, len(s.Indexes))
+	for _, index := range s.Indexes {
+		definition := "INDEX"
+		if index.Unique {
+			definition = "UNIQUE INDEX"
+		}
+		definition += fmt.Sprintf(" ON %s (%s)", index.Table, strings.Join(index.Columns, ", "))
+		if index.Where != "" {
+			definition += " WHERE " + index.Where
+		}
+		definitions[index.Name] = definition
+	}
+	return definitions
+}
+
+func enumDefinitions(s *Schema) map[string]string {
+	definitions := make(map[string]string, len(s.Enums))
+	for _, enum := range s.Enums {
+		definitions[enum.Name] = fmt.Sprintf("ENUM ('%s')", strings.Join(enum.Values, "', '"))
+	}
+	return definitions
+}
+
+func triggerDefinitions(s *Schema) map[string]string {
+	definitions := make(map[string]string, len(s.Triggers))
+	for _, trigger := range s.Triggers {
+		definitions[trigger.Name+" on "+trigger.Table] = ""
+	}
+	return definitions
+}
@@ -0,0 +1,130 @@
+package ddl
+
+import (
+	"reflect"
+	"testing"
+)
+
+// buildSchema applies the statements of sql to an empty schema.
+func buildSchema(t *testing.T, sql string) *Schema {
+	t.Helper()
+	statements, err := Parse(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSchema()
+	err = s.ApplyAll(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDiff(t *testing.T) {
+	const users = "CREATE TABLE users (id SERIAL PRIMARY KEY, name VARCHAR(255) NOT NULL);\n"
+
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		want     []string
+	}{
+		{
+			name:     "same schema",
+			expected: users,
+			actual:   users,
+			want:     []string{},
+		},
+		{
+			name:     "type aliases",
+			expected: "CREATE TABLE users (id INTEGER, name character varying(20));",
+			actual:   "CREATE TABLE users (id int4, name VARCHAR(20));",
+			want:     []string{},
+		},
+		{
+			name:     "column order",
+			expected: users + "ALTER TABLE users ADD COLUMN email TEXT;",
+			actual:   "CREATE TABLE users (id SERIAL PRIMARY KEY, email TEXT, name VARCHAR(255) NOT NULL);",
+			want:     []string{},
+		},
+		{
+			name:     "missing and left over tables",
+			expected: users,
+			actual:   "CREATE TABLE posts (id SERIAL PRIMARY KEY);",
+			want:     []string{"table posts is left over", "table users is missing"},
+		},
+		{
+			name:     "column changed",
+			expected: users,
+			actual:   users + "ALTER TABLE users ALTER COLUMN name DROP NOT NULL, ALTER COLUMN name SET DEFAULT 'x';",
+			want:     []string{"column users.name is varchar(255) DEFAULT 'x', expected varchar(255) NOT NULL"},
+		},
+		{
+			name:     "left over column",
+			expected: users,
+			actual:   users + "ALTER TABLE users ADD COLUMN age INTEGER;",
+			want:     []string{"column users.age is left over"},
+		},
+		{
+			name:     "missing index",
+			expected: users + "CREATE UNIQUE INDEX uniq_users_name ON users (name);",
+			actual:   users,
+			want:     []string{"index uniq_users_name is missing"},
+		},
+		{
+			name:     "index changed",
+			expected: users + "CREATE INDEX idx_users_name ON users (name);",
+			actual:   users + "CREATE INDEX idx_users_name ON users (name) WHERE name <> '';",
+			want:     []string{"index idx_users_name is INDEX ON users (name) WHERE name <> '', expected INDEX ON users (name)"},
+		},
+		{
+			name:     "enum values",
+			expected: "CREATE TYPE status AS ENUM ('new', 'done');",
+			actual:   "CREATE TYPE status AS ENUM ('new');",
+			want:     []string{"type status is ENUM ('new'), expected ENUM ('new', 'done')"},
+		},
+		{
+			name:     "foreign key",
+			expected: users + "CREATE TABLE posts (id SERIAL PRIMARY KEY, user_id INTEGER REFERENCES users(id) ON DELETE CASCADE);",
+			actual:   users + "CREATE TABLE posts (id SERIAL PRIMARY KEY, user_id INTEGER);",
+			want:     []string{"constraint posts.posts_user_id_fkey is missing"},
+		},
+		{
+			name:     "left over trigger",
+			expected: users,
+			actual:   users + "CREATE TRIGGER users_touch BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION touch();",
+			want:     []string{"trigger users_touch on users is left over"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Diff(buildSchema(t, tt.expected), buildSchema(t, tt.actual))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	const sql = "CREATE TABLE users (id SERIAL PRIMARY KEY, team_id INTEGER REFERENCES teams(id));\nCREATE TYPE status AS ENUM ('new');\nCREATE INDEX idx_users_team_id ON users (team_id);"
+	s := buildSchema(t, sql)
+	clone := s.Clone()
+
+	statements, err := Parse("DROP INDEX idx_users_team_id;\nALTER TABLE users DROP COLUMN team_id;\nDROP TYPE status;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = clone.ApplyAll(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diffs := Diff(buildSchema(t, sql), s); len(diffs) > 0 {
+		t.Errorf("original changed with its clone: %q", diffs)
+	}
+	if diffs := Diff(s, clone); len(diffs) != 4 {
+		t.Errorf("clone diffs = %q, want the column, its foreign key, the index and the type", diffs)
+	}
+}
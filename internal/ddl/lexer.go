@@ -0,0 +1,223 @@
+package ddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+	pos  int
+	end  int
+}
+
+// is reports whether the token is the given keyword; keywords are matched
+// case-insensitively against bare identifiers only.
+func (t token) is(word string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (t token) isSymbol(symbol string) bool {
+	return t.kind == tokSymbol && t.text == symbol
+}
+
+// tokenize splits SQL text into tokens, dropping whitespace and comments.
+// Unquoted identifiers are folded to lower case the way Postgres does.
+func tokenize(sql string) ([]token, error) {
+	tokens := make([]token, 0, len(sql)/4)
+	line := 1
+	i := 0
+
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case c == '-' && i+1 < len(sql) && sql[i+1] == '-':
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			comment := sql[i : i+2+end+2]
+			line += strings.Count(comment, "\n")
+			i += len(comment)
+		case c == '\'' || ((c == 'E' || c == 'e') && i+1 < len(sql) && sql[i+1] == '\''):
+			start := i
+			escapes := c != '\''
+			if escapes {
+				i++
+			}
+			value, n, err := scanQuoted(sql[i:], '\'', escapes)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			tokens = append(tokens, token{kind: tokString, text: value, line: line, pos: start, end: i + n})
+			line += strings.Count(sql[i:i+n], "\n")
+			i += n
+		case c == '"':
+			value, n, err := scanQuoted(sql[i:], '"', false)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			tokens = append(tokens, token{kind: tokQuotedIdent, text: value, line: line, pos: i, end: i + n})
+			i += n
+		case c == '$' && i+1 < len(sql) && (sql[i+1] == '$' || isIdentStart(sql[i+1])):
+			tagEnd := strings.IndexByte(sql[i+1:], '$')
+			if tagEnd < 0 {
+				return nil, fmt.Errorf("line %d: unterminated dollar quote", line)
+			}
+			tag := sql[i : i+1+tagEnd+1]
+			bodyStart := i + len(tag)
+			bodyEnd := strings.Index(sql[bodyStart:], tag)
+			if bodyEnd < 0 {
+				return nil, fmt.Errorf("line %d: unterminated dollar quote %s", line, tag)
+			}
+			body := sql[bodyStart : bodyStart+bodyEnd]
+			end := bodyStart + bodyEnd + len(tag)
+			tokens = append(tokens, token{kind: tokString, text: body, line: line, pos: i, end: end})
+			line += strings.Count(sql[i:end], "\n")
+			i = end
+		case isIdentStart(c):
+			start := i
+			for i < len(sql) && isIdentPart(sql[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: strings.ToLower(sql[start:i]), line: line, pos: start, end: i})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(sql) && (sql[i] >= '0' && sql[i] <= '9' || sql[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: sql[start:i], line: line, pos: start, end: i})
+		default:
+			symbol := string(c)
+			if i+1 < len(sql) {
+				switch sql[i : i+2] {
+				case "::", "<>", "!=", "<=", ">=", "||":
+					symbol = sql[i : i+2]
+				}
+			}
+			tokens = append(tokens, token{kind: tokSymbol, text: symbol, line: line, pos: i, end: i + len(symbol)})
+			i += len(symbol)
+		}
+	}
+
+	return tokens, nil
+}
+
+// scanQuoted reads a quoted literal starting at s[0] and returns its
+// unescaped value and the number of bytes consumed. Backslash escapes are
+// honoured only for E-prefixed strings.
+func scanQuoted(s string, quote byte, escapes bool) (string, int, error) {
+	var sb strings.Builder
+	i := 1
+	for i < len(s) {
+		if escapes && s[i] == '\\' && i+1 < len(s) {
+			sb.WriteByte(s[i+1])
+			i += 2
+			continue
+		}
+		if s[i] == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				sb.WriteByte(quote)
+				i += 2
+				continue
+			}
+			return sb.String(), i + 1, nil
+		}
+		sb.WriteByte(s[i])
+		i++
+	}
+	return "", 0, fmt.Errorf("unterminated quoted literal")
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '$'
+}
+
+// joinTokens renders tokens back into compact SQL text, used for types and
+// expressions that are kept verbatim.
+func joinTokens(tokens []token) string {
+	var sb strings.Builder
+	for i, tok := range tokens {
+		if i > 0 && needsSpace(tokens[i-1], tok) {
+			sb.WriteByte(' ')
+		}
+		switch tok.kind {
+		case tokString:
+			sb.WriteString("'" + strings.ReplaceAll(tok.text, "'", "''") + "'")
+		case tokQuotedIdent:
+			sb.WriteString(quoteIdentIfNeeded(tok.text))
+		default:
+			sb.WriteString(tok.text)
+		}
+	}
+	return sb.String()
+}
+
+func needsSpace(prev, cur token) bool {
+	if prev.kind == tokSymbol {
+		switch prev.text {
+		case "(", "::", ".", "[":
+			return false
+		case ",", "=", "<>", "!=", "<", ">", "<=", ">=", "||", "+", "-", "*", "/":
+			return true
+		}
+		return prev.text != ")" || cur.kind != tokSymbol
+	}
+	if cur.kind == tokSymbol {
+		switch cur.text {
+		case ",", ")", "::", ".", "[", "]":
+			return false
+		case "(":
+			return prev.kind != tokIdent && prev.kind != tokQuotedIdent || isKeyword(prev.text)
+		}
+	}
+	return true
+}
+
+// isKeyword lists words that are followed by a parenthesised list rather than
+// called as functions, so they keep a space before "(".
+func isKeyword(word string) bool {
+	switch strings.ToLower(word) {
+	case "in", "and", "or", "not", "check", "key", "references", "using", "exists", "values", "where", "as", "enum":
+		return true
+	}
+	return false
+}
+
+func quoteIdentIfNeeded(name string) string {
+	if name == "" {
+		return `""`
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !(c == '_' || c >= 'a' && c <= 'z' || i > 0 && c >= '0' && c <= '9') {
+			return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+		}
+	}
+	return name
+}
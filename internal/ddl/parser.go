@@ -0,0 +1,887 @@
+package ddl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Parse splits SQL text into statements and interprets the DDL subset
+// codegenex works with. Statements outside that subset are returned as
+// *Other so callers can decide whether to ignore them.
+func Parse(sql string) ([]Statement, error) {
+	tokens, err := tokenize(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := make([]Statement, 0)
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && !tokens[i].isSymbol(";") {
+			continue
+		}
+		if i > start {
+			group := tokens[start:i]
+			base := stmtBase{
+				line: group[0].line,
+				sql:  sql[group[0].pos:group[len(group)-1].end],
+			}
+			stmt, err := parseStatement(group, base)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, stmt)
+		}
+		start = i + 1
+	}
+
+	return statements, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.toks)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokSymbol, line: p.line()}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.peek()
+	if !p.done() {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) line() int {
+	switch {
+	case len(p.toks) == 0:
+		return 0
+	case p.pos < len(p.toks):
+		return p.toks[p.pos].line
+	default:
+		return p.toks[len(p.toks)-1].line
+	}
+}
+
+// accept consumes the keyword sequence if the upcoming tokens match it.
+func (p *parser) accept(words ...string) bool {
+	for i, word := range words {
+		if p.pos+i >= len(p.toks) || !p.toks[p.pos+i].is(word) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) expect(words ...string) error {
+	if !p.accept(words...) {
+		return p.errorf("expected %s, found %q", strings.ToUpper(strings.Join(words, " ")), p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if p.peek().isSymbol(symbol) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return p.errorf("expected %q, found %q", symbol, p.peek().text)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line(), fmt.Sprintf(format, args...))
+}
+
+// name reads a possibly schema-qualified identifier and returns its last
+// part, so public.users and users refer to the same table.
+func (p *parser) name() (string, error) {
+	tok := p.next()
+	if tok.kind != tokIdent && tok.kind != tokQuotedIdent {
+		return "", p.errorf("expected identifier, found %q", tok.text)
+	}
+	name := tok.text
+	for p.peek().isSymbol(".") {
+		p.next()
+		tok = p.next()
+		if tok.kind != tokIdent && tok.kind != tokQuotedIdent {
+			return "", p.errorf("expected identifier, found %q", tok.text)
+		}
+		name = tok.text
+	}
+	return name, nil
+}
+
+// nameList reads a parenthesised, comma separated list of identifiers.
+func (p *parser) nameList() ([]string, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if p.acceptSymbol(")") {
+			return names, nil
+		}
+		if err := p.expectSymbol(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// until collects tokens up to, but not including, the first token at
+// parenthesis depth zero for which stop returns true.
+func (p *parser) until(stop func(token) bool) []token {
+	start := p.pos
+	depth := 0
+	for !p.done() {
+		tok := p.peek()
+		if depth == 0 && stop(tok) {
+			break
+		}
+		switch {
+		case tok.isSymbol("("), tok.isSymbol("["):
+			depth++
+		case tok.isSymbol(")"), tok.isSymbol("]"):
+			if depth == 0 {
+				return p.toks[start:p.pos]
+			}
+			depth--
+		}
+		p.pos++
+	}
+	return p.toks[start:p.pos]
+}
+
+// parenthesised reads a balanced "( ... )" group and returns the inner tokens.
+func (p *parser) parenthesised() ([]token, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	inner := p.until(func(token) bool { return false })
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
+
+// source returns the statement text the tokens were read from, with runs
+// of whitespace collapsed, so keywords keep the case they were written in.
+func (p *parser) source(base stmtBase, tokens []token) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	offset := p.toks[0].pos
+	text := base.sql[tokens[0].pos-offset : tokens[len(tokens)-1].end-offset]
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// splitList splits tokens on top-level commas.
+func splitList(tokens []token) [][]token {
+	parts := make([][]token, 0)
+	depth := 0
+	start := 0
+	for i, tok := range tokens {
+		switch {
+		case tok.isSymbol("("), tok.isSymbol("["):
+			depth++
+		case tok.isSymbol(")"), tok.isSymbol("]"):
+			depth--
+		case tok.isSymbol(",") && depth == 0:
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		parts = append(parts, tokens[start:])
+	}
+	return parts
+}
+
+func parseStatement(tokens []token, base stmtBase) (Statement, error) {
+	p := &parser{toks: tokens}
+
+	switch {
+	case p.accept("create", "table"), p.accept("create", "unlogged", "table"):
+		return p.parseCreateTable(base)
+	case p.accept("create", "type"):
+		return p.parseCreateType(base)
+	case p.accept("create", "index"):
+		return p.parseCreateIndex(base, false)
+	case p.accept("create", "unique", "index"):
+		return p.parseCreateIndex(base, true)
+	case p.accept("alter", "table"):
+		return p.parseAlterTable(base)
+	case p.accept("alter", "index"):
+		return p.parseAlterIndex(base)
+	case p.accept("drop", "table"):
+		stmt := &DropTable{stmtBase: base}
+		stmt.IfExists = p.accept("if", "exists")
+		names, err := p.dropNames()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Names = names
+		stmt.Cascade = p.accept("cascade")
+		return stmt, nil
+	case p.accept("drop", "index"):
+		stmt := &DropIndex{stmtBase: base}
+		stmt.Concurrently = p.accept("concurrently")
+		stmt.IfExists = p.accept("if", "exists")
+		names, err := p.dropNames()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Names = names
+		return stmt, nil
+	case p.accept("drop", "type"):
+		stmt := &DropType{stmtBase: base}
+		stmt.IfExists = p.accept("if", "exists")
+		names, err := p.dropNames()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Names = names
+		return stmt, nil
+	case p.accept("create", "trigger"):
+		return p.parseCreateTrigger(base, false)
+	case p.accept("create", "or", "replace", "trigger"):
+		return p.parseCreateTrigger(base, true)
+	case p.accept("drop", "trigger"):
+		stmt := &DropTrigger{stmtBase: base}
+		stmt.IfExists = p.accept("if", "exists")
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Name = name
+		if err := p.expect("on"); err != nil {
+			return nil, err
+		}
+		table, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Table = table
+		return stmt, nil
+	case p.accept("create", "function"):
+		return p.parseCreateFunction(base, false)
+	case p.accept("create", "or", "replace", "function"):
+		return p.parseCreateFunction(base, true)
+	case p.accept("drop", "function"):
+		stmt := &DropFunction{stmtBase: base}
+		stmt.IfExists = p.accept("if", "exists")
+		for {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Names = append(stmt.Names, name)
+			if p.peek().isSymbol("(") {
+				if _, err := p.parenthesised(); err != nil {
+					return nil, err
+				}
+			}
+			if !p.acceptSymbol(",") {
+				return stmt, nil
+			}
+		}
+	case p.accept("do"):
+		return p.parseDo(base)
+	}
+
+	return &Other{stmtBase: base, Keyword: strings.ToLower(tokens[0].text)}, nil
+}
+
+func (p *parser) parseCreateTable(base stmtBase) (Statement, error) {
+	stmt := &CreateTable{stmtBase: base}
+	stmt.IfNotExists = p.accept("if", "not", "exists")
+
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Name = name
+
+	elements, err := p.parenthesised()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, element := range splitList(elements) {
+		if len(element) == 0 {
+			continue
+		}
+		ep := &parser{toks: element}
+		if isTableConstraintStart(element[0]) {
+			constraint, err := ep.parseTableConstraint()
+			if err != nil {
+				return nil, err
+			}
+			if constraint != nil {
+				stmt.Constraints = append(stmt.Constraints, constraint)
+			}
+			continue
+		}
+		if element[0].is("like") || element[0].is("exclude") {
+			continue
+		}
+
+		column, err := ep.parseColumn()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Columns = append(stmt.Columns, column)
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) parseCreateType(base stmtBase) (Statement, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("as", "enum") {
+		return &Other{stmtBase: base, Keyword: "create"}, nil
+	}
+
+	values, err := p.parenthesised()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &CreateEnum{stmtBase: base, Name: name}
+	for _, value := range values {
+		switch {
+		case value.kind == tokString:
+			stmt.Values = append(stmt.Values, value.text)
+		case value.isSymbol(","):
+		default:
+			return nil, p.errorf("unexpected %q in enum %s", value.text, name)
+		}
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) parseCreateIndex(base stmtBase, unique bool) (Statement, error) {
+	stmt := &CreateIndex{stmtBase: base, Index: &Index{Unique: unique}}
+	stmt.Concurrently = p.accept("concurrently")
+	stmt.IfNotExists = p.accept("if", "not", "exists")
+
+	if !p.peek().is("on") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Index.Name = name
+	}
+	if err := p.expect("on"); err != nil {
+		return nil, err
+	}
+	p.accept("only")
+
+	table, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Index.Table = table
+
+	if p.accept("using") {
+		stmt.Index.Method = strings.ToLower(p.next().text)
+	}
+
+	elements, err := p.parenthesised()
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range splitList(elements) {
+		stmt.Index.Columns = append(stmt.Index.Columns, indexElement(element))
+	}
+
+	for !p.done() {
+		if p.accept("where") {
+			stmt.Index.Where = joinTokens(p.until(func(token) bool { return false }))
+			break
+		}
+		p.next()
+	}
+
+	return stmt, nil
+}
+
+// indexElement returns the column name of a plain index element, or the
+// expression text for expression indexes.
+func indexElement(element []token) string {
+	if len(element) > 0 && (element[0].kind == tokIdent || element[0].kind == tokQuotedIdent) {
+		plain := true
+		for _, tok := range element[1:] {
+			if !(tok.is("asc") || tok.is("desc") || tok.is("nulls") || tok.is("first") || tok.is("last")) {
+				plain = false
+				break
+			}
+		}
+		if plain {
+			return element[0].text
+		}
+	}
+	return joinTokens(element)
+}
+
+// dropNames reads the comma separated names of a DROP statement.
+func (p *parser) dropNames() ([]string, error) {
+	names := make([]string, 0, 1)
+	for {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if !p.acceptSymbol(",") {
+			return names, nil
+		}
+	}
+}
+
+func (p *parser) parseCreateTrigger(base stmtBase, orReplace bool) (Statement, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &CreateTrigger{stmtBase: base, Name: name, OrReplace: orReplace}
+
+	// BEFORE INSERT OR UPDATE OF column ON table
+	stmt.Timing = p.source(base, p.until(func(tok token) bool { return tok.is("on") }))
+	if err := p.expect("on"); err != nil {
+		return nil, err
+	}
+	table, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Table = table
+	stmt.Action = p.source(base, p.until(func(token) bool { return false }))
+	return stmt, nil
+}
+
+func (p *parser) parseCreateFunction(base stmtBase, orReplace bool) (Statement, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	args, err := p.parenthesised()
+	if err != nil {
+		return nil, err
+	}
+	return &CreateFunction{stmtBase: base, Name: name, Args: joinTokens(args), OrReplace: orReplace}, nil
+}
+
+func (p *parser) parseAlterIndex(base stmtBase) (Statement, error) {
+	stmt := &AlterIndex{stmtBase: base}
+	stmt.IfExists = p.accept("if", "exists")
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("rename", "to") {
+		return &Other{stmtBase: base, Keyword: "alter"}, nil
+	}
+	newName, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	stmt.Name = name
+	stmt.NewName = newName
+	return stmt, nil
+}
+
+// parseDo picks the CREATE TYPE ... AS ENUM statements out of the body of
+// a DO block.
+func (p *parser) parseDo(base stmtBase) (Statement, error) {
+	stmt := &Do{stmtBase: base}
+	for !p.done() && p.peek().kind != tokString {
+		p.next()
+	}
+	bodyToken := p.next()
+	if bodyToken.kind != tokString {
+		return nil, p.errorf("missing body of DO block")
+	}
+
+	tokens, err := tokenize(bodyToken.text)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", bodyToken.line, err)
+	}
+	for i := range tokens {
+		tokens[i].line += bodyToken.line - 1
+	}
+
+	for i := 0; i+1 < len(tokens); i++ {
+		if !tokens[i].is("create") || !tokens[i+1].is("type") {
+			continue
+		}
+		end := i + 2
+		for end < len(tokens) && !tokens[end].isSymbol(";") {
+			end++
+		}
+		bp := &parser{toks: tokens[i+2 : end]}
+		nested, err := bp.parseCreateType(stmtBase{line: tokens[i].line, sql: bodyToken.text[tokens[i].pos:tokens[end-1].end]})
+		if err != nil {
+			return nil, err
+		}
+		if enum, ok := nested.(*CreateEnum); ok {
+			enum.IfNotExists = true
+			stmt.Statements = append(stmt.Statements, enum)
+		}
+		i = end
+	}
+	return stmt, nil
+}
+
+func (p *parser) parseAlterTable(base stmtBase) (Statement, error) {
+	ifExists := p.accept("if", "exists")
+	p.accept("only")
+
+	table, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &AlterTable{stmtBase: base, Table: table, IfExists: ifExists}
+
+	rest := p.until(func(token) bool { return false })
+	for _, part := range splitList(rest) {
+		if len(part) == 0 {
+			continue
+		}
+		ap := &parser{toks: part}
+		action, err := ap.parseAlterAction()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Actions = append(stmt.Actions, action)
+	}
+
+	return stmt, nil
+}
+
+func (p *parser) parseAlterAction() (*AlterAction, error) {
+	switch {
+	case p.accept("add"):
+		if isTableConstraintStart(p.peek()) {
+			constraint, err := p.parseTableConstraint()
+			if err != nil {
+				return nil, err
+			}
+			if constraint == nil {
+				return &AlterAction{Kind: OtherAlter}, nil
+			}
+			return &AlterAction{Kind: AddConstraint, Constraint: constraint}, nil
+		}
+		p.accept("column")
+		action := &AlterAction{Kind: AddColumn}
+		action.IfNotExists = p.accept("if", "not", "exists")
+		column, err := p.parseColumn()
+		if err != nil {
+			return nil, err
+		}
+		action.Column = column
+		action.ColumnName = column.Name
+		return action, nil
+	case p.accept("alter"):
+		p.accept("column")
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		action := &AlterAction{Kind: OtherAlter, ColumnName: name}
+		switch {
+		case p.accept("set", "default"):
+			action.Kind = SetDefault
+			action.Default = joinTokens(p.until(func(token) bool { return false }))
+		case p.accept("drop", "default"):
+			action.Kind = DropDefault
+		case p.accept("set", "not", "null"):
+			action.Kind = SetNotNull
+		case p.accept("drop", "not", "null"):
+			action.Kind = DropNotNull
+		case p.accept("type"), p.accept("set", "data", "type"):
+			action.Kind = SetType
+			typeTokens := p.until(func(tok token) bool { return tok.is("using") || tok.is("collate") })
+			action.Type = NormalizeType(joinTokens(unqualify(typeTokens)))
+		}
+		return action, nil
+	case p.accept("drop"):
+		if p.accept("constraint") {
+			action := &AlterAction{Kind: DropConstraint}
+			action.IfExists = p.accept("if", "exists")
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			action.ConstraintName = name
+			return action, nil
+		}
+		p.accept("column")
+		action := &AlterAction{Kind: DropColumn}
+		action.IfExists = p.accept("if", "exists")
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		action.ColumnName = name
+		return action, nil
+	case p.accept("rename"):
+		switch {
+		case p.accept("to"):
+			newName, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return &AlterAction{Kind: RenameTable, NewName: newName}, nil
+		case p.accept("constraint"):
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("to"); err != nil {
+				return nil, err
+			}
+			newName, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			return &AlterAction{Kind: RenameConstraint, ConstraintName: name, NewName: newName}, nil
+		}
+		p.accept("column")
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("to"); err != nil {
+			return nil, err
+		}
+		newName, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return &AlterAction{Kind: RenameColumn, ColumnName: name, NewName: newName}, nil
+	}
+
+	return &AlterAction{Kind: OtherAlter}, nil
+}
+
+var columnConstraintKeywords = []string{
+	"constraint", "not", "null", "default", "primary", "unique", "references", "check", "generated", "collate",
+}
+
+func isColumnConstraintKeyword(tok token) bool {
+	for _, word := range columnConstraintKeywords {
+		if tok.is(word) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTableConstraintStart(tok token) bool {
+	return tok.is("constraint") || tok.is("primary") || tok.is("unique") || tok.is("foreign") || tok.is("check") || tok.is("exclude")
+}
+
+func (p *parser) parseColumn() (*Column, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	column := &Column{Name: name}
+
+	typeTokens := p.until(isColumnConstraintKeyword)
+	if len(typeTokens) == 0 {
+		return nil, p.errorf("missing type for column %s", name)
+	}
+	column.Type = NormalizeType(joinTokens(unqualify(typeTokens)))
+
+	for !p.done() {
+		switch {
+		case p.accept("constraint"):
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+		case p.accept("not", "null"):
+			column.NotNull = true
+		case p.accept("null"):
+			column.NotNull = false
+		case p.accept("default"):
+			expr := []token{p.next()}
+			expr = append(expr, p.until(isColumnConstraintKeyword)...)
+			column.Default = joinTokens(expr)
+		case p.accept("primary", "key"):
+			column.PrimaryKey = true
+			column.NotNull = true
+		case p.accept("unique"):
+			column.Unique = true
+		case p.accept("references"):
+			ref, err := p.parseReference()
+			if err != nil {
+				return nil, err
+			}
+			ref.Columns = []string{name}
+			column.References = ref
+		case p.accept("check"):
+			if _, err := p.parenthesised(); err != nil {
+				return nil, err
+			}
+		case p.accept("generated"):
+			clause := p.until(func(tok token) bool { return isColumnConstraintKeyword(tok) && !tok.is("default") })
+			column.Generated = strings.TrimSpace("GENERATED " + joinTokens(clause))
+		case p.accept("collate"):
+			if _, err := p.name(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, p.errorf("unexpected %q in column %s", p.peek().text, name)
+		}
+	}
+
+	return column, nil
+}
+
+// parseTableConstraint reads a table level constraint. Constraint kinds
+// that codegenex does not model (EXCLUDE) are skipped and reported as nil.
+func (p *parser) parseTableConstraint() (*Constraint, error) {
+	constraint := &Constraint{}
+	if p.accept("constraint") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		constraint.Name = name
+	}
+
+	switch {
+	case p.accept("primary", "key"):
+		columns, err := p.nameList()
+		if err != nil {
+			return nil, err
+		}
+		constraint.Kind = PrimaryKeyConstraint
+		constraint.Columns = columns
+	case p.accept("unique"):
+		columns, err := p.nameList()
+		if err != nil {
+			return nil, err
+		}
+		constraint.Kind = UniqueConstraint
+		constraint.Columns = columns
+	case p.accept("foreign", "key"):
+		columns, err := p.nameList()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("references"); err != nil {
+			return nil, err
+		}
+		ref, err := p.parseReference()
+		if err != nil {
+			return nil, err
+		}
+		ref.Name = constraint.Name
+		ref.Columns = columns
+		return ref, nil
+	case p.accept("check"):
+		expr, err := p.parenthesised()
+		if err != nil {
+			return nil, err
+		}
+		constraint.Kind = CheckConstraint
+		constraint.Check = joinTokens(expr)
+	default:
+		p.until(func(token) bool { return false })
+		return nil, nil
+	}
+
+	p.until(func(token) bool { return false })
+	return constraint, nil
+}
+
+// parseReference reads the part of a foreign key after REFERENCES.
+func (p *parser) parseReference() (*Constraint, error) {
+	table, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	ref := &Constraint{Kind: ForeignKeyConstraint, RefTable: table, OnDelete: "NO ACTION"}
+
+	if p.peek().isSymbol("(") {
+		columns, err := p.nameList()
+		if err != nil {
+			return nil, err
+		}
+		ref.RefColumns = columns
+	} else {
+		ref.RefColumns = []string{"id"}
+	}
+
+	for !p.done() && !isColumnConstraintKeyword(p.peek()) {
+		switch {
+		case p.accept("on", "delete"):
+			ref.OnDelete = p.referentialAction()
+		case p.accept("on", "update"):
+			p.referentialAction()
+		default:
+			p.next()
+		}
+	}
+
+	return ref, nil
+}
+
+func (p *parser) referentialAction() string {
+	switch {
+	case p.accept("cascade"):
+		return "CASCADE"
+	case p.accept("set", "null"):
+		return "SET NULL"
+	case p.accept("set", "default"):
+		return "SET DEFAULT"
+	case p.accept("restrict"):
+		return "RESTRICT"
+	case p.accept("no", "action"):
+		return "NO ACTION"
+	}
+	return strings.ToUpper(p.next().text)
+}
+
+// unqualify drops schema prefixes from names inside a token run, turning
+// public.mood into mood.
+func unqualify(tokens []token) []token {
+	result := make([]token, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		if i+2 < len(tokens) && (tokens[i].kind == tokIdent || tokens[i].kind == tokQuotedIdent) && tokens[i+1].isSymbol(".") {
+			i++
+			continue
+		}
+		result = append(result, tokens[i])
+	}
+	return result
+}
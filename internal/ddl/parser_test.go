@@ -0,0 +1,191 @@
+package ddl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStatements(t *testing.T) {
+	tests := []struct {
+		name  string
+		sql   string
+		want  []string
+		lines []int
+	}{
+		{
+			name:  "create table",
+			sql:   "CREATE TABLE IF NOT EXISTS users (id SERIAL PRIMARY KEY);",
+			want:  []string{"*ddl.CreateTable"},
+			lines: []int{1},
+		},
+		{
+			name:  "statements on later lines",
+			sql:   "SET statement_timeout = 0;\n\nCREATE INDEX idx_users_name ON users (name);\nDROP TABLE users;",
+			want:  []string{"*ddl.Other", "*ddl.CreateIndex", "*ddl.DropTable"},
+			lines: []int{1, 3, 4},
+		},
+		{
+			name:  "comments and empty statements",
+			sql:   "-- +goose Up\n/* block\ncomment */ ;;\nALTER TABLE users ADD COLUMN name TEXT;",
+			want:  []string{"*ddl.AlterTable"},
+			lines: []int{4},
+		},
+		{
+			name:  "semicolon in literal",
+			sql:   "ALTER TABLE users ALTER COLUMN name SET DEFAULT 'a;b';",
+			want:  []string{"*ddl.AlterTable"},
+			lines: []int{1},
+		},
+		{
+			name:  "do block with enum",
+			sql:   "DO $$\nBEGIN\n  IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'status') THEN\n    CREATE TYPE status AS ENUM ('new', 'done');\n  END IF;\nEND$$;",
+			want:  []string{"*ddl.Do"},
+			lines: []int{1},
+		},
+		{
+			name:  "function and trigger",
+			sql:   "CREATE OR REPLACE FUNCTION touch() RETURNS trigger AS $body$ BEGIN RETURN NEW; END; $body$ LANGUAGE plpgsql;\nCREATE TRIGGER users_touch BEFORE UPDATE ON users FOR EACH ROW EXECUTE FUNCTION touch();",
+			want:  []string{"*ddl.CreateFunction", "*ddl.CreateTrigger"},
+			lines: []int{1, 2},
+		},
+		{
+			name:  "drops",
+			sql:   "DROP INDEX CONCURRENTLY IF EXISTS idx_a;\nDROP TYPE IF EXISTS status;\nDROP TRIGGER IF EXISTS users_touch ON users;\nDROP FUNCTION IF EXISTS touch();",
+			want:  []string{"*ddl.DropIndex", "*ddl.DropType", "*ddl.DropTrigger", "*ddl.DropFunction"},
+			lines: []int{1, 2, 3, 4},
+		},
+		{
+			name:  "rename index",
+			sql:   "ALTER INDEX IF EXISTS idx_a RENAME TO idx_b;",
+			want:  []string{"*ddl.AlterIndex"},
+			lines: []int{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, err := Parse(tt.sql)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]string, 0, len(statements))
+			lines := make([]int, 0, len(statements))
+			for _, stmt := range statements {
+				got = append(got, fmt.Sprintf("%T", stmt))
+				lines = append(lines, stmt.Line())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("lines = %v, want %v", lines, tt.lines)
+			}
+		})
+	}
+}
+
+func TestParseCreateTable(t *testing.T) {
+	sql := `CREATE TABLE public.orders (
+    id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    number character varying(20) NOT NULL UNIQUE,
+    total NUMERIC(10, 2) DEFAULT 0 NOT NULL,
+    tags text[],
+    user_id INT4 REFERENCES users(id) ON DELETE CASCADE,
+    CONSTRAINT chk_orders_total CHECK (total >= 0)
+);`
+	statements, err := Parse(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("statements = %d, want 1", len(statements))
+	}
+	create, ok := statements[0].(*CreateTable)
+	if !ok {
+		t.Fatalf("statement = %T, want *ddl.CreateTable", statements[0])
+	}
+	if create.Name != "orders" {
+		t.Errorf("name = %q, want orders", create.Name)
+	}
+
+	want := []string{
+		"id bigint GENERATED always as identity PRIMARY KEY",
+		"number varchar(20) NOT NULL UNIQUE",
+		"total numeric(10,2) NOT NULL DEFAULT 0",
+		"tags text[]",
+		"user_id integer",
+	}
+	got := make([]string, 0, len(create.Columns))
+	for _, column := range create.Columns {
+		got = append(got, column.Definition())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("columns =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	references := create.Columns[4].References
+	if references == nil || references.RefTable != "users" || references.OnDelete != "CASCADE" {
+		t.Errorf("references of user_id = %+v, want users on delete cascade", references)
+	}
+	if len(create.Constraints) != 1 || create.Constraints[0].Definition() != "CHECK (total >= 0)" {
+		t.Errorf("constraints = %+v, want the total check", create.Constraints)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{name: "unterminated comment", sql: "SELECT 1;\n/* open", want: "line 2: unterminated comment"},
+		{name: "unterminated literal", sql: "\n\nALTER TABLE users ALTER COLUMN name SET DEFAULT 'open;", want: "line 3: unterminated quoted literal"},
+		{name: "unterminated dollar quote", sql: "DO $$ BEGIN", want: "line 1: unterminated dollar quote"},
+		{name: "unterminated tagged dollar quote", sql: "DO $body$ BEGIN", want: "line 1: unterminated dollar quote $body$"},
+		{name: "create table without columns", sql: "SELECT 1;\nCREATE TABLE users;", want: "line 2:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.sql)
+			if err == nil {
+				t.Fatalf("Parse() succeeded, want error %q", tt.want)
+			}
+			if !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("error = %q, want prefix %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeType(t *testing.T) {
+	tests := []struct {
+		sqlType string
+		want    string
+	}{
+		{sqlType: "INTEGER", want: "integer"},
+		{sqlType: "int4", want: "integer"},
+		{sqlType: "INT8", want: "bigint"},
+		{sqlType: "character varying(255)", want: "varchar(255)"},
+		{sqlType: "VARCHAR(255)", want: "varchar(255)"},
+		{sqlType: "decimal(10, 2)", want: "numeric(10,2)"},
+		{sqlType: "timestamp without time zone", want: "timestamp"},
+		{sqlType: "timestamp(6) with time zone", want: "timestamptz(6)"},
+		{sqlType: "TIMESTAMPTZ", want: "timestamptz"},
+		{sqlType: "text[]", want: "text[]"},
+		{sqlType: "INT4[]", want: "integer[]"},
+		{sqlType: "float8", want: "double precision"},
+		{sqlType: "citext", want: "citext"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sqlType, func(t *testing.T) {
+			got := NormalizeType(tt.sqlType)
+			if got != tt.want {
+				t.Errorf("NormalizeType(%q) = %q, want %q", tt.sqlType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,687 @@
+package ddl
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrUnknownTable is wrapped by errors of statements on tables the schema
+// does not have.
+var ErrUnknownTable = errors.New("unknown table")
+
+// Schema is an in-memory model of a Postgres schema built by applying
+// parsed statements in order.
+type Schema struct {
+	Tables    []*Table
+	Enums     []*Enum
+	Indexes   []*Index
+	Triggers  []*Trigger
+	Functions []*Function
+}
+
+type Table struct {
+	Name        string
+	Columns     []*Column
+	Constraints []*Constraint
+}
+
+type Enum struct {
+	Name   string
+	Values []string
+}
+
+type Trigger struct {
+	Name   string
+	Table  string
+	Timing string
+	Action string
+}
+
+// Function is a function created by the statements. Its body is not
+// modelled, SQL is the CREATE FUNCTION statement as written.
+type Function struct {
+	Name string
+	Args string
+	SQL  string
+}
+
+func NewSchema() *Schema {
+	return &Schema{}
+}
+
+func (s *Schema) Table(name string) *Table {
+	for _, table := range s.Tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+func (s *Schema) Enum(name string) *Enum {
+	for _, enum := range s.Enums {
+		if enum.Name == name {
+			return enum
+		}
+	}
+	return nil
+}
+
+// TableIndexes returns the indexes defined on the given table.
+func (s *Schema) TableIndexes(table string) []*Index {
+	indexes := make([]*Index, 0)
+	for _, index := range s.Indexes {
+		if index.Table == table {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+func (t *Table) Column(name string) *Column {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// ForeignKey returns the foreign key that constrains the single column,
+// whether declared inline or as a table constraint.
+func (t *Table) ForeignKey(column string) *Constraint {
+	if c := t.Column(column); c != nil && c.References != nil {
+		return c.References
+	}
+	for _, constraint := range t.Constraints {
+		if constraint.Kind == ForeignKeyConstraint && len(constraint.Columns) == 1 && constraint.Columns[0] == column {
+			return constraint
+		}
+	}
+	return nil
+}
+
+// PrimaryKey returns the primary key columns of the table.
+func (t *Table) PrimaryKey() []string {
+	for _, column := range t.Columns {
+		if column.PrimaryKey {
+			return []string{column.Name}
+		}
+	}
+	for _, constraint := range t.Constraints {
+		if constraint.Kind == PrimaryKeyConstraint {
+			return constraint.Columns
+		}
+	}
+	return nil
+}
+
+// IsUnique reports whether the single column carries a unique constraint.
+func (t *Table) IsUnique(column string) bool {
+	if c := t.Column(column); c != nil && c.Unique {
+		return true
+	}
+	for _, constraint := range t.Constraints {
+		if constraint.Kind == UniqueConstraint && len(constraint.Columns) == 1 && constraint.Columns[0] == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ApplyAll applies the statements in order, stopping at the first error.
+func (s *Schema) ApplyAll(statements []Statement) error {
+	for _, stmt := range statements {
+		if err := s.Apply(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Apply changes the schema according to a single statement. Statements that
+// do not affect the modelled objects are ignored.
+func (s *Schema) Apply(stmt Statement) error {
+	switch stmt := stmt.(type) {
+	case *CreateTable:
+		if s.Table(stmt.Name) != nil {
+			if stmt.IfNotExists {
+				return nil
+			}
+			return fmt.Errorf("line %d: table %s already exists", stmt.Line(), stmt.Name)
+		}
+		s.Tables = append(s.Tables, &Table{
+			Name:        stmt.Name,
+			Columns:     stmt.Columns,
+			Constraints: stmt.Constraints,
+		})
+	case *CreateEnum:
+		if s.Enum(stmt.Name) != nil {
+			if stmt.IfNotExists {
+				return nil
+			}
+			return fmt.Errorf("line %d: type %s already exists", stmt.Line(), stmt.Name)
+		}
+		s.Enums = append(s.Enums, &Enum{Name: stmt.Name, Values: stmt.Values})
+	case *CreateIndex:
+		if stmt.Index.Name != "" && s.index(stmt.Index.Name) != nil {
+			if stmt.IfNotExists {
+				return nil
+			}
+			return fmt.Errorf("line %d: index %s already exists", stmt.Line(), stmt.Index.Name)
+		}
+		if s.Table(stmt.Index.Table) == nil {
+			return fmt.Errorf("line %d: index %s on %w %s", stmt.Line(), stmt.Index.Name, ErrUnknownTable, stmt.Index.Table)
+		}
+		s.Indexes = append(s.Indexes, stmt.Index)
+	case *AlterTable:
+		table := s.Table(stmt.Table)
+		if table == nil {
+			if stmt.IfExists {
+				return nil
+			}
+			return fmt.Errorf("line %d: alter of %w %s", stmt.Line(), ErrUnknownTable, stmt.Table)
+		}
+		for _, action := range stmt.Actions {
+			if err := s.applyAlter(table, action); err != nil {
+				return fmt.Errorf("line %d: %w", stmt.Line(), err)
+			}
+		}
+	case *DropTable:
+		for _, name := range stmt.Names {
+			if err := s.dropTable(name, stmt.IfExists, stmt.Cascade); err != nil {
+				return fmt.Errorf("line %d: %w", stmt.Line(), err)
+			}
+		}
+	case *DropIndex:
+		for _, name := range stmt.Names {
+			if s.index(name) == nil {
+				if stmt.IfExists {
+					continue
+				}
+				return fmt.Errorf("line %d: unknown index %s", stmt.Line(), name)
+			}
+			s.removeIndexes(func(index *Index) bool { return index.Name == name })
+		}
+	case *AlterIndex:
+		index := s.index(stmt.Name)
+		if index == nil {
+			if stmt.IfExists {
+				return nil
+			}
+			return fmt.Errorf("line %d: unknown index %s", stmt.Line(), stmt.Name)
+		}
+		if s.index(stmt.NewName) != nil {
+			return fmt.Errorf("line %d: index %s already exists", stmt.Line(), stmt.NewName)
+		}
+		index.Name = stmt.NewName
+	case *DropType:
+		for _, name := range stmt.Names {
+			if err := s.dropEnum(name, stmt.IfExists); err != nil {
+				return fmt.Errorf("line %d: %w", stmt.Line(), err)
+			}
+		}
+	case *CreateTrigger:
+		if s.Table(stmt.Table) == nil {
+			return fmt.Errorf("line %d: trigger %s on %w %s", stmt.Line(), stmt.Name, ErrUnknownTable, stmt.Table)
+		}
+		if s.trigger(stmt.Name, stmt.Table) != nil {
+			if stmt.OrReplace {
+				return nil
+			}
+			return fmt.Errorf("line %d: trigger %s on %s already exists", stmt.Line(), stmt.Name, stmt.Table)
+		}
+		s.Triggers = append(s.Triggers, &Trigger{Name: stmt.Name, Table: stmt.Table, Timing: stmt.Timing, Action: stmt.Action})
+	case *DropTrigger:
+		if s.trigger(stmt.Name, stmt.Table) == nil {
+			if stmt.IfExists {
+				return nil
+			}
+			return fmt.Errorf("line %d: unknown trigger %s on %s", stmt.Line(), stmt.Name, stmt.Table)
+		}
+		s.removeTriggers(func(trigger *Trigger) bool {
+			return trigger.Name == stmt.Name && trigger.Table == stmt.Table
+		})
+	case *CreateFunction:
+		if function := s.function(stmt.Name); function != nil {
+			if !stmt.OrReplace {
+				return fmt.Errorf("line %d: function %s already exists", stmt.Line(), stmt.Name)
+			}
+			function.Args = stmt.Args
+			function.SQL = stmt.SQL()
+			return nil
+		}
+		s.Functions = append(s.Functions, &Function{Name: stmt.Name, Args: stmt.Args, SQL: stmt.SQL()})
+	case *DropFunction:
+		for _, name := range stmt.Names {
+			if s.function(name) == nil {
+				if stmt.IfExists {
+					continue
+				}
+				return fmt.Errorf("line %d: unknown function %s", stmt.Line(), name)
+			}
+			functions := make([]*Function, 0, len(s.Functions))
+			for _, function := range s.Functions {
+				if function.Name != name {
+					functions = append(functions, function)
+				}
+			}
+			s.Functions = functions
+		}
+	case *Do:
+		return s.ApplyAll(stmt.Statements)
+	}
+	return nil
+}
+
+// applyAlter applies an ALTER TABLE action, including its effects on the
+// indexes and on the other tables of the schema.
+func (s *Schema) applyAlter(table *Table, action *AlterAction) error {
+	switch action.Kind {
+	case RenameTable:
+		if s.Table(action.NewName) != nil {
+			return fmt.Errorf("table %s already exists", action.NewName)
+		}
+		for _, index := range s.TableIndexes(table.Name) {
+			index.Table = action.NewName
+		}
+		for _, trigger := range s.Triggers {
+			if trigger.Table == table.Name {
+				trigger.Table = action.NewName
+			}
+		}
+		for _, fk := range s.referencesTo(table.Name) {
+			fk.RefTable = action.NewName
+		}
+		table.Name = action.NewName
+		return nil
+	case DropColumn:
+		if err := table.apply(action); err != nil {
+			return err
+		}
+		// indexes on the column go with it
+		s.removeIndexes(func(index *Index) bool {
+			return index.Table == table.Name && containsString(index.Columns, action.ColumnName)
+		})
+		return nil
+	case RenameColumn:
+		if err := table.apply(action); err != nil {
+			return err
+		}
+		for _, index := range s.TableIndexes(table.Name) {
+			renameString(index.Columns, action.ColumnName, action.NewName)
+		}
+		for _, fk := range s.referencesTo(table.Name) {
+			renameString(fk.RefColumns, action.ColumnName, action.NewName)
+		}
+		return nil
+	}
+	return table.apply(action)
+}
+
+func (s *Schema) dropTable(name string, ifExists, cascade bool) error {
+	table := s.Table(name)
+	if table == nil {
+		if ifExists {
+			return nil
+		}
+		return fmt.Errorf("drop of %w %s", ErrUnknownTable, name)
+	}
+
+	for _, other := range s.Tables {
+		if other.Name == name {
+			continue
+		}
+		for _, constraint := range other.AllConstraints() {
+			if constraint.Kind != ForeignKeyConstraint || constraint.RefTable != name {
+				continue
+			}
+			if !cascade {
+				return fmt.Errorf("table %s is still referenced by %s (%s)", name, constraint.Name, other.Name)
+			}
+			other.dropConstraint(constraint.Name)
+		}
+	}
+
+	s.removeIndexes(func(index *Index) bool { return index.Table == name })
+	s.removeTriggers(func(trigger *Trigger) bool { return trigger.Table == name })
+	tables := make([]*Table, 0, len(s.Tables))
+	for _, t := range s.Tables {
+		if t != table {
+			tables = append(tables, t)
+		}
+	}
+	s.Tables = tables
+	return nil
+}
+
+func (s *Schema) dropEnum(name string, ifExists bool) error {
+	if s.Enum(name) == nil {
+		if ifExists {
+			return nil
+		}
+		return fmt.Errorf("unknown type %s", name)
+	}
+	for _, table := range s.Tables {
+		for _, column := range table.Columns {
+			if strings.TrimSuffix(column.Type, "[]") == name {
+				return fmt.Errorf("type %s is still used by column %s.%s", name, table.Name, column.Name)
+			}
+		}
+	}
+
+	enums := make([]*Enum, 0, len(s.Enums))
+	for _, enum := range s.Enums {
+		if enum.Name != name {
+			enums = append(enums, enum)
+		}
+	}
+	s.Enums = enums
+	return nil
+}
+
+// referencesTo returns the foreign keys of all tables that point to table.
+func (s *Schema) referencesTo(table string) []*Constraint {
+	references := make([]*Constraint, 0)
+	for _, other := range s.Tables {
+		for _, column := range other.Columns {
+			if column.References != nil && column.References.RefTable == table {
+				references = append(references, column.References)
+			}
+		}
+		for _, constraint := range other.Constraints {
+			if constraint.Kind == ForeignKeyConstraint && constraint.RefTable == table {
+				references = append(references, constraint)
+			}
+		}
+	}
+	return references
+}
+
+func (s *Schema) removeIndexes(remove func(*Index) bool) {
+	indexes := make([]*Index, 0, len(s.Indexes))
+	for _, index := range s.Indexes {
+		if !remove(index) {
+			indexes = append(indexes, index)
+		}
+	}
+	s.Indexes = indexes
+}
+
+func (s *Schema) trigger(name, table string) *Trigger {
+	for _, trigger := range s.Triggers {
+		if trigger.Name == name && trigger.Table == table {
+			return trigger
+		}
+	}
+	return nil
+}
+
+func (s *Schema) removeTriggers(remove func(*Trigger) bool) {
+	triggers := make([]*Trigger, 0, len(s.Triggers))
+	for _, trigger := range s.Triggers {
+		if !remove(trigger) {
+			triggers = append(triggers, trigger)
+		}
+	}
+	s.Triggers = triggers
+}
+
+func (s *Schema) function(name string) *Function {
+	for _, function := range s.Functions {
+		if function.Name == name {
+			return function
+		}
+	}
+	return nil
+}
+
+func (s *Schema) index(name string) *Index {
+	for _, index := range s.Indexes {
+		if index.Name == name {
+			return index
+		}
+	}
+	return nil
+}
+
+func (t *Table) apply(action *AlterAction) error {
+	switch action.Kind {
+	case AddColumn:
+		if t.Column(action.Column.Name) != nil {
+			if action.IfNotExists {
+				return nil
+			}
+			return fmt.Errorf("column %s.%s already exists", t.Name, action.Column.Name)
+		}
+		t.Columns = append(t.Columns, action.Column)
+	case AddConstraint:
+		t.Constraints = append(t.Constraints, action.Constraint)
+	case DropColumn:
+		if t.Column(action.ColumnName) == nil {
+			if action.IfExists {
+				return nil
+			}
+			return fmt.Errorf("unknown column %s.%s", t.Name, action.ColumnName)
+		}
+		columns := make([]*Column, 0, len(t.Columns))
+		for _, column := range t.Columns {
+			if column.Name != action.ColumnName {
+				columns = append(columns, column)
+			}
+		}
+		t.Columns = columns
+		constraints := make([]*Constraint, 0, len(t.Constraints))
+		for _, constraint := range t.Constraints {
+			if !containsString(constraint.Columns, action.ColumnName) {
+				constraints = append(constraints, constraint)
+			}
+		}
+		t.Constraints = constraints
+	case RenameColumn:
+		column := t.Column(action.ColumnName)
+		if column == nil {
+			return fmt.Errorf("unknown column %s.%s", t.Name, action.ColumnName)
+		}
+		if t.Column(action.NewName) != nil {
+			return fmt.Errorf("column %s.%s already exists", t.Name, action.NewName)
+		}
+		column.Name = action.NewName
+		for _, constraint := range t.Constraints {
+			renameString(constraint.Columns, action.ColumnName, action.NewName)
+		}
+	case DropConstraint:
+		if !t.dropConstraint(action.ConstraintName) && !action.IfExists {
+			return fmt.Errorf("unknown constraint %s on %s", action.ConstraintName, t.Name)
+		}
+	case RenameConstraint:
+		constraint := t.constraint(action.ConstraintName)
+		if constraint == nil {
+			return fmt.Errorf("unknown constraint %s on %s", action.ConstraintName, t.Name)
+		}
+		t.dropConstraint(action.ConstraintName)
+		renamed := *constraint
+		renamed.Name = action.NewName
+		t.Constraints = append(t.Constraints, &renamed)
+	case SetDefault, DropDefault, SetNotNull, DropNotNull, SetType:
+		column := t.Column(action.ColumnName)
+		if column == nil {
+			return fmt.Errorf("unknown column %s.%s", t.Name, action.ColumnName)
+		}
+		switch action.Kind {
+		case SetDefault:
+			column.Default = action.Default
+		case DropDefault:
+			column.Default = ""
+		case SetNotNull:
+			column.NotNull = true
+		case DropNotNull:
+			column.NotNull = false
+		case SetType:
+			column.Type = action.Type
+		}
+	}
+	return nil
+}
+
+// AllConstraints returns the table constraints together with the ones
+// declared on columns. Constraints without a name get the name Postgres
+// would generate.
+func (t *Table) AllConstraints() []*Constraint {
+	constraints := make([]*Constraint, 0, len(t.Constraints))
+	for _, column := range t.Columns {
+		if column.PrimaryKey {
+			constraints = append(constraints, &Constraint{
+				Name:    t.Name + "_pkey",
+				Kind:    PrimaryKeyConstraint,
+				Columns: []string{column.Name},
+			})
+		}
+		if column.Unique {
+			constraints = append(constraints, &Constraint{
+				Name:    t.Name + "_" + column.Name + "_key",
+				Kind:    UniqueConstraint,
+				Columns: []string{column.Name},
+			})
+		}
+		if column.References != nil {
+			constraints = append(constraints, t.named(column.References))
+		}
+	}
+	for _, constraint := range t.Constraints {
+		constraints = append(constraints, t.named(constraint))
+	}
+	return constraints
+}
+
+// named gives an unnamed constraint its generated name. Named constraints
+// are returned as they are so callers can change them.
+func (t *Table) named(constraint *Constraint) *Constraint {
+	if constraint.Name != "" {
+		return constraint
+	}
+	named := *constraint
+	switch constraint.Kind {
+	case PrimaryKeyConstraint:
+		named.Name = t.Name + "_pkey"
+	case UniqueConstraint:
+		named.Name = t.Name + "_" + strings.Join(constraint.Columns, "_") + "_key"
+	case ForeignKeyConstraint:
+		named.Name = t.Name + "_" + strings.Join(constraint.Columns, "_") + "_fkey"
+	case CheckConstraint:
+		named.Name = t.Name + "_check"
+	}
+	return &named
+}
+
+func (t *Table) constraint(name string) *Constraint {
+	for _, constraint := range t.AllConstraints() {
+		if constraint.Name == name {
+			return constraint
+		}
+	}
+	return nil
+}
+
+// dropConstraint removes the constraint with the given name and reports
+// whether there was one.
+func (t *Table) dropConstraint(name string) bool {
+	for _, column := range t.Columns {
+		switch {
+		case column.PrimaryKey && name == t.Name+"_pkey":
+			column.PrimaryKey = false
+			return true
+		case column.Unique && name == t.Name+"_"+column.Name+"_key":
+			column.Unique = false
+			return true
+		case column.References != nil && t.named(column.References).Name == name:
+			column.References = nil
+			return true
+		}
+	}
+	for i, constraint := range t.Constraints {
+		if t.named(constraint).Name == name {
+			t.Constraints = append(t.Constraints[:i:i], t.Constraints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func renameString(values []string, oldValue, newValue string) {
+	for i, v := range values {
+		if v == oldValue {
+			values[i] = newValue
+		}
+	}
+}
+
+var (
+	typeModifierPattern = regexp.MustCompile(`^([a-z][a-z0-9 ]*?)\s*(\(.*\))?$`)
+	typeAliases         = map[string]string{
+		"int":                         "integer",
+		"int4":                        "integer",
+		"int8":                        "bigint",
+		"int2":                        "smallint",
+		"serial4":                     "serial",
+		"serial8":                     "bigserial",
+		"bool":                        "boolean",
+		"character varying":           "varchar",
+		"character":                   "char",
+		"timestamp without time zone": "timestamp",
+		"timestamp with time zone":    "timestamptz",
+		"time without time zone":      "time",
+		"float8":                      "double precision",
+		"float4":                      "real",
+		"decimal":                     "numeric",
+	}
+)
+
+// NormalizeType maps Postgres type spellings and aliases to one canonical
+// lower case form, e.g. "INT4" and "integer" both become "integer".
+func NormalizeType(sqlType string) string {
+	sqlType = strings.ToLower(strings.TrimSpace(sqlType))
+
+	suffix := ""
+	for strings.HasSuffix(sqlType, "[]") {
+		suffix += "[]"
+		sqlType = strings.TrimSpace(strings.TrimSuffix(sqlType, "[]"))
+	}
+
+	// timestamp(6) with time zone keeps its precision in the middle
+	if strings.HasPrefix(sqlType, "timestamp") {
+		withZone := strings.HasSuffix(sqlType, "with time zone") && !strings.HasSuffix(sqlType, "without time zone")
+		precision := ""
+		if open := strings.Index(sqlType, "("); open >= 0 {
+			if end := strings.Index(sqlType, ")"); end > open {
+				precision = sqlType[open : end+1]
+			}
+		}
+		base := "timestamp"
+		if withZone || strings.HasPrefix(sqlType, "timestamptz") {
+			base = "timestamptz"
+		}
+		return base + precision + suffix
+	}
+
+	match := typeModifierPattern.FindStringSubmatch(sqlType)
+	if match == nil {
+		return sqlType + suffix
+	}
+	base, modifier := match[1], strings.ReplaceAll(match[2], " ", "")
+	if alias, ok := typeAliases[base]; ok {
+		base = alias
+	}
+	return base + modifier + suffix
+}
@@ -0,0 +1,199 @@
+package ddl
+
+// Statement is a single parsed SQL statement. Line and SQL point back to the
+// source text so callers can report positions and reproduce statements that
+// are kept verbatim.
+type Statement interface {
+	Line() int
+	SQL() string
+}
+
+type stmtBase struct {
+	line int
+	sql  string
+}
+
+func (b stmtBase) Line() int   { return b.line }
+func (b stmtBase) SQL() string { return b.sql }
+
+type CreateTable struct {
+	stmtBase
+	Name        string
+	IfNotExists bool
+	Columns     []*Column
+	Constraints []*Constraint
+}
+
+type CreateEnum struct {
+	stmtBase
+	Name   string
+	Values []string
+	// IfNotExists is set for enums created inside a DO block, which
+	// generated migrations guard with a pg_type lookup.
+	IfNotExists bool
+}
+
+type CreateIndex struct {
+	stmtBase
+	Index        *Index
+	IfNotExists  bool
+	Concurrently bool
+}
+
+type AlterTable struct {
+	stmtBase
+	Table    string
+	IfExists bool
+	Actions  []*AlterAction
+}
+
+type DropTable struct {
+	stmtBase
+	Names    []string
+	IfExists bool
+	Cascade  bool
+}
+
+type DropIndex struct {
+	stmtBase
+	Names        []string
+	IfExists     bool
+	Concurrently bool
+}
+
+type DropType struct {
+	stmtBase
+	Names    []string
+	IfExists bool
+}
+
+// AlterIndex renames an index. Other ALTER INDEX forms are parsed as
+// *Other.
+type AlterIndex struct {
+	stmtBase
+	Name     string
+	NewName  string
+	IfExists bool
+}
+
+type CreateTrigger struct {
+	stmtBase
+	Name      string
+	Table     string
+	OrReplace bool
+	// Timing is the part between the name and ON, e.g. BEFORE UPDATE, and
+	// Action the part after the table, e.g. FOR EACH ROW EXECUTE FUNCTION.
+	Timing string
+	Action string
+}
+
+type DropTrigger struct {
+	stmtBase
+	Name     string
+	Table    string
+	IfExists bool
+}
+
+// CreateFunction keeps the function definition only as its source text.
+type CreateFunction struct {
+	stmtBase
+	Name      string
+	Args      string
+	OrReplace bool
+}
+
+type DropFunction struct {
+	stmtBase
+	Names    []string
+	IfExists bool
+}
+
+// Do is an anonymous code block. Statements holds the enums its body
+// creates; everything else in the body is left alone.
+type Do struct {
+	stmtBase
+	Statements []Statement
+}
+
+// Other is any statement the parser does not interpret, such as SET or
+// CREATE SEQUENCE in a pg_dump file.
+type Other struct {
+	stmtBase
+	Keyword string
+}
+
+type AlterKind int
+
+const (
+	AddColumn AlterKind = iota
+	AddConstraint
+	SetDefault
+	DropDefault
+	SetNotNull
+	DropNotNull
+	SetType
+	DropColumn
+	RenameColumn
+	DropConstraint
+	RenameConstraint
+	RenameTable
+	OtherAlter
+)
+
+type AlterAction struct {
+	Kind       AlterKind
+	Column     *Column
+	ColumnName string
+	Constraint *Constraint
+	Default    string
+	// Type is the normalized new type of SetType.
+	Type string
+	// ConstraintName names the constraint of DropConstraint and
+	// RenameConstraint.
+	ConstraintName string
+	// NewName is the new name of RenameColumn, RenameConstraint and
+	// RenameTable.
+	NewName     string
+	IfNotExists bool
+	IfExists    bool
+}
+
+type Column struct {
+	Name       string
+	Type       string
+	NotNull    bool
+	Default    string
+	PrimaryKey bool
+	Unique     bool
+	References *Constraint
+	// Generated is the GENERATED clause of identity and generated columns.
+	Generated string
+}
+
+type ConstraintKind int
+
+const (
+	PrimaryKeyConstraint ConstraintKind = iota
+	UniqueConstraint
+	ForeignKeyConstraint
+	CheckConstraint
+)
+
+type Constraint struct {
+	Name       string
+	Kind       ConstraintKind
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+	Check      string
+}
+
+type Index struct {
+	Name    string
+	Table   string
+	Columns []string
+	Unique  bool
+	Method  string
+	Where   string
+}
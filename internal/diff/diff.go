@@ -0,0 +1,214 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+const contextLines = 3
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+}
+
+// Unified returns a unified diff between two texts, or an empty string when
+// they are equal. An empty name is rendered as /dev/null.
+func Unified(fromName, toName string, from, to []byte) string {
+	a := splitLines(string(from))
+	b := splitLines(string(to))
+
+	ops := editScript(a, b)
+	hunks := buildHunks(ops)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", displayName(fromName), displayName(toName))
+	for _, h := range hunks {
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(h.aStart, h.aCount), hunkRange(h.bStart, h.bCount))
+		for _, o := range h.ops {
+			sb.WriteByte(byte(o.kind))
+			sb.WriteString(o.line)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+func displayName(name string) string {
+	if name == "" {
+		return "/dev/null"
+	}
+	return name
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// editScript computes a shortest edit script with the Myers algorithm.
+func editScript(a, b []string) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	trace := make([][]int, 0)
+
+	found := false
+	for d := 0; d <= max && !found; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+
+	ops := make([]op, 0, n+m)
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, op{opEqual, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, op{opInsert, b[y-1]})
+			y--
+		} else {
+			ops = append(ops, op{opDelete, a[x-1]})
+			x--
+		}
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, op{opEqual, a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+type hunk struct {
+	aStart, aCount int
+	bStart, bCount int
+	ops            []op
+}
+
+// buildHunks groups changes with up to contextLines of surrounding context,
+// merging hunks whose context would overlap.
+func buildHunks(ops []op) []hunk {
+	hunks := make([]hunk, 0)
+
+	var current *hunk
+	aLine, bLine := 0, 0
+	lastChange := -1
+
+	for i, o := range ops {
+		if o.kind != opEqual {
+			if current == nil || i-lastChange-1 > 2*contextLines {
+				if current != nil {
+					current.ops = append(current.ops, ops[lastChange+1:lastChange+1+contextLines]...)
+					hunks = append(hunks, countLines(*current))
+				}
+				start := i - contextLines
+				if start < 0 {
+					start = 0
+				}
+				current = &hunk{aStart: aLine - (i - start), bStart: bLine - (i - start)}
+				current.ops = append(current.ops, ops[start:i]...)
+			} else {
+				current.ops = append(current.ops, ops[lastChange+1:i]...)
+			}
+			current.ops = append(current.ops, o)
+			lastChange = i
+		}
+
+		switch o.kind {
+		case opEqual:
+			aLine++
+			bLine++
+		case opDelete:
+			aLine++
+		case opInsert:
+			bLine++
+		}
+	}
+
+	if current != nil {
+		end := lastChange + 1 + contextLines
+		if end > len(ops) {
+			end = len(ops)
+		}
+		current.ops = append(current.ops, ops[lastChange+1:end]...)
+		hunks = append(hunks, countLines(*current))
+	}
+
+	return hunks
+}
+
+// countLines counts the lines of each side covered by the hunk.
+func countLines(h hunk) hunk {
+	for _, o := range h.ops {
+		switch o.kind {
+		case opEqual:
+			h.aCount++
+			h.bCount++
+		case opDelete:
+			h.aCount++
+		case opInsert:
+			h.bCount++
+		}
+	}
+	return h
+}
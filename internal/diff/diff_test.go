@@ -0,0 +1,66 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		fromName string
+		toName   string
+		from     string
+		to       string
+		want     string
+	}{
+		{
+			name:     "equal",
+			fromName: "a/x", toName: "b/x",
+			from: "one\ntwo\n", to: "one\ntwo\n",
+			want: "",
+		},
+		{
+			name:     "new file",
+			fromName: "", toName: "b/x",
+			from: "", to: "one\ntwo\n",
+			want: "--- /dev/null\n+++ b/x\n@@ -0,0 +1,2 @@\n+one\n+two\n",
+		},
+		{
+			name:     "deleted file",
+			fromName: "a/x", toName: "",
+			from: "one\n", to: "",
+			want: "--- a/x\n+++ /dev/null\n@@ -1 +0,0 @@\n-one\n",
+		},
+		{
+			name:     "change with context",
+			fromName: "a/x", toName: "b/x",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n", to: "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- a/x\n+++ b/x\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:     "insert at the end",
+			fromName: "a/x", toName: "b/x",
+			from: "1\n2\n", to: "1\n2\n3\n",
+			want: "--- a/x\n+++ b/x\n@@ -1,2 +1,3 @@\n 1\n 2\n+3\n",
+		},
+		{
+			name:     "changes six lines apart share a hunk",
+			fromName: "a/x", toName: "b/x",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n", to: "one\n2\n3\n4\n5\n6\n7\neight\n",
+			want: "--- a/x\n+++ b/x\n@@ -1,8 +1,8 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n",
+		},
+		{
+			name:     "distant changes get separate hunks",
+			fromName: "a/x", toName: "b/x",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n", to: "one\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			want: "--- a/x\n+++ b/x\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -6,4 +6,4 @@\n 6\n 7\n 8\n-9\n+nine\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Unified(tt.fromName, tt.toName, []byte(tt.from), []byte(tt.to))
+			if got != tt.want {
+				t.Errorf("Unified() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Commit writes the changes to disk as a whole. New contents are first
+// written to temporary files next to their targets and then renamed over
+// them, so a failure while writing leaves the disk untouched, and a failure
+// while renaming restores the files already replaced.
+func Commit(changes []Change) error {
+	temps := make(map[string]string, len(changes))
+	removeTemps := func() {
+		for _, temp := range temps {
+			os.Remove(temp)
+		}
+	}
+
+	for _, change := range changes {
+		if change.Deleted {
+			continue
+		}
+		temp, err := writeTemp(change.Path, change.After)
+		if err != nil {
+			removeTemps()
+			return fmt.Errorf("error writing %s: %w", change.Path, err)
+		}
+		temps[change.Path] = temp
+	}
+
+	for i, change := range changes {
+		var err error
+		if change.Deleted {
+			err = os.Remove(change.Path)
+		} else {
+			err = os.Rename(temps[change.Path], change.Path)
+			if err == nil {
+				delete(temps, change.Path)
+			}
+		}
+		if err != nil {
+			err = fmt.Errorf("error committing %s: %w", change.Path, err)
+			removeTemps()
+			return errors.Join(err, rollback(changes[:i]))
+		}
+	}
+	return nil
+}
+
+// rollback restores the files of the applied changes, newest first.
+func rollback(applied []Change) error {
+	var errs []error
+	for i := len(applied) - 1; i >= 0; i-- {
+		change := applied[i]
+		var err error
+		if change.Existed {
+			err = os.WriteFile(change.Path, change.Before, 0644)
+		} else {
+			err = os.Remove(change.Path)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error restoring %s: %w", change.Path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeTemp writes data to a hidden temporary file in the directory of
+// name, creating the directory if needed, and returns its path. The Go and
+// goose tooling skip such files if one is ever left behind.
+func writeTemp(name string, data []byte) (string, error) {
+	dir := filepath.Dir(name)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
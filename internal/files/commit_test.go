@@ -0,0 +1,80 @@
+package files
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCommit(t *testing.T) {
+	initial := map[string]string{
+		"models/user.go":  "package models // user",
+		"models/post.go":  "package models // post",
+		"state.json":      "{}",
+		"blocked/file.go": "package blocked",
+	}
+
+	tests := []struct {
+		name    string
+		stage   func(m *Memory, dir string)
+		wantErr bool
+		want    map[string]string
+	}{
+		{
+			name: "all applied",
+			stage: func(m *Memory, dir string) {
+				m.WriteFile(filepath.Join(dir, "models/user.go"), []byte("package models // user v2"), 0644)
+				m.WriteFile(filepath.Join(dir, "migrations/1_create_users.sql"), []byte("-- up"), 0644)
+				m.Remove(filepath.Join(dir, "models/post.go"))
+			},
+			want: map[string]string{
+				"models/user.go":                "package models // user v2",
+				"migrations/1_create_users.sql": "-- up",
+				"state.json":                    "{}",
+				"blocked/file.go":               "package blocked",
+			},
+		},
+		{
+			name: "rename fails",
+			stage: func(m *Memory, dir string) {
+				m.WriteFile(filepath.Join(dir, "models/user.go"), []byte("package models // user v2"), 0644)
+				m.WriteFile(filepath.Join(dir, "migrations/1_create_users.sql"), []byte("-- up"), 0644)
+				m.Remove(filepath.Join(dir, "models/post.go"))
+				// a non-empty directory cannot be replaced by a file
+				m.WriteFile(filepath.Join(dir, "blocked"), []byte("file"), 0644)
+				m.WriteFile(filepath.Join(dir, "state.json"), []byte(`{"entities": []}`), 0644)
+			},
+			wantErr: true,
+			want:    initial,
+		},
+		{
+			name: "write fails",
+			stage: func(m *Memory, dir string) {
+				m.WriteFile(filepath.Join(dir, "models/user.go"), []byte("package models // user v2"), 0644)
+				// the parent of the file is a regular file
+				m.WriteFile(filepath.Join(dir, "state.json/nested"), []byte("{}"), 0644)
+			},
+			wantErr: true,
+			want:    initial,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, initial)
+			m := NewMemory(OS)
+			tt.stage(m, dir)
+
+			err := Commit(m.Changes())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Commit() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			got := readFiles(t, dir)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
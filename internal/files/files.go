@@ -0,0 +1,191 @@
+package files
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FS is the file system the generators read from and write to. OS works on
+// the real disk; Memory keeps writes in memory on top of another FS.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm os.FileMode) error
+	Remove(name string) error
+	MkdirAll(path string, perm os.FileMode) error
+	Exists(name string) bool
+	// ReadDir returns the sorted names of the regular files in dir.
+	ReadDir(dir string) ([]string, error)
+}
+
+var OS FS = osFS{}
+
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return os.ReadFile(name)
+}
+
+func (osFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+
+func (osFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (osFS) MkdirAll(path string, perm os.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+
+func (osFS) Exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func (osFS) ReadDir(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Change describes how a file differs between the base FS and a Memory
+// layered on top of it.
+type Change struct {
+	Path    string
+	Before  []byte
+	After   []byte
+	Existed bool
+	Deleted bool
+}
+
+type memFile struct {
+	data    []byte
+	deleted bool
+}
+
+// Memory is an in-memory FS layered over base: reads fall through to base
+// until a file is written or removed, and nothing reaches base.
+type Memory struct {
+	base  FS
+	files map[string]*memFile
+	order []string
+}
+
+func NewMemory(base FS) *Memory {
+	return &Memory{base: base, files: make(map[string]*memFile)}
+}
+
+func (m *Memory) ReadFile(name string) ([]byte, error) {
+	name = filepath.Clean(name)
+	if f, ok := m.files[name]; ok {
+		if f.deleted {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		return append([]byte(nil), f.data...), nil
+	}
+	return m.base.ReadFile(name)
+}
+
+func (m *Memory) WriteFile(name string, data []byte, perm os.FileMode) error {
+	m.set(filepath.Clean(name), &memFile{data: append([]byte(nil), data...)})
+	return nil
+}
+
+func (m *Memory) Remove(name string) error {
+	name = filepath.Clean(name)
+	if !m.Exists(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	m.set(name, &memFile{deleted: true})
+	return nil
+}
+
+func (m *Memory) MkdirAll(path string, perm os.FileMode) error {
+	return nil
+}
+
+func (m *Memory) Exists(name string) bool {
+	name = filepath.Clean(name)
+	if f, ok := m.files[name]; ok {
+		return !f.deleted
+	}
+	return m.base.Exists(name)
+}
+
+func (m *Memory) ReadDir(dir string) ([]string, error) {
+	dir = filepath.Clean(dir)
+	names, err := m.base.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	baseMissing := err != nil
+
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+	}
+	for path, f := range m.files {
+		if filepath.Dir(path) != dir {
+			continue
+		}
+		present[filepath.Base(path)] = !f.deleted
+	}
+
+	result := make([]string, 0, len(present))
+	for name, ok := range present {
+		if ok {
+			result = append(result, name)
+		}
+	}
+	if baseMissing && len(result) == 0 {
+		return nil, err
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Changes lists the files whose content differs from base, in the order
+// they were first touched.
+func (m *Memory) Changes() []Change {
+	changes := make([]Change, 0, len(m.order))
+	for _, path := range m.order {
+		f := m.files[path]
+		before, err := m.base.ReadFile(path)
+		existed := err == nil
+
+		switch {
+		case f.deleted && !existed:
+			continue
+		case !f.deleted && existed && string(before) == string(f.data):
+			continue
+		}
+
+		changes = append(changes, Change{
+			Path:    path,
+			Before:  before,
+			After:   f.data,
+			Existed: existed,
+			Deleted: f.deleted,
+		})
+	}
+	return changes
+}
+
+func (m *Memory) set(name string, f *memFile) {
+	if _, ok := m.files[name]; !ok {
+		m.order = append(m.order, name)
+	}
+	m.files[name] = f
+}
@@ -0,0 +1,116 @@
+package files
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFiles creates the files under dir, given by relative path.
+func writeFiles(t *testing.T, dir string, contents map[string]string) {
+	t.Helper()
+	for name, content := range contents {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFiles returns the contents of all regular files under dir by relative
+// path, temporary files included.
+func readFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	contents := make(map[string]string)
+	err := filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		contents[rel] = string(data)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contents
+}
+
+func TestMemory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"models/user.go": "package models // user",
+		"models/post.go": "package models // post",
+		"state.json":     "{}",
+	})
+	m := NewMemory(OS)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	m.WriteFile(path("models/user.go"), []byte("package models // user v2"), 0644)
+	m.WriteFile(path("migrations/1_create_users.sql"), []byte("-- up"), 0644)
+	m.WriteFile(path("state.json"), []byte("{}"), 0644)
+	m.Remove(path("models/post.go"))
+	m.WriteFile(path("tmp.go"), []byte("package tmp"), 0644)
+	m.Remove(path("tmp.go"))
+
+	data, err := m.ReadFile(path("models/user.go"))
+	if err != nil || string(data) != "package models // user v2" {
+		t.Errorf("ReadFile() = %q, %v, want the written content", data, err)
+	}
+	_, err = m.ReadFile(path("models/post.go"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadFile() of a removed file error = %v, want fs.ErrNotExist", err)
+	}
+	if m.Exists(path("models/post.go")) || !m.Exists(path("state.json")) {
+		t.Error("Exists() does not reflect the removal")
+	}
+	err = m.Remove(path("models/post.go"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Remove() of a removed file error = %v, want fs.ErrNotExist", err)
+	}
+
+	names, err := m.ReadDir(path("models"))
+	if err != nil || !reflect.DeepEqual(names, []string{"user.go"}) {
+		t.Errorf("ReadDir(models) = %q, %v, want [user.go]", names, err)
+	}
+	names, err = m.ReadDir(path("migrations"))
+	if err != nil || !reflect.DeepEqual(names, []string{"1_create_users.sql"}) {
+		t.Errorf("ReadDir(migrations) = %q, %v, want [1_create_users.sql]", names, err)
+	}
+	_, err = m.ReadDir(path("missing"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("ReadDir() of a missing directory error = %v, want fs.ErrNotExist", err)
+	}
+
+	// unchanged writes and files both created and removed are left out
+	want := []Change{
+		{Path: path("models/user.go"), Before: []byte("package models // user"), After: []byte("package models // user v2"), Existed: true},
+		{Path: path("migrations/1_create_users.sql"), After: []byte("-- up")},
+		{Path: path("models/post.go"), Before: []byte("package models // post"), Existed: true, Deleted: true},
+	}
+	got := m.Changes()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes() = %+v, want %+v", got, want)
+	}
+
+	// nothing reaches the disk
+	contents := readFiles(t, dir)
+	if len(contents) != 3 || contents["models/user.go"] != "package models // user" {
+		t.Errorf("files on disk = %v, want them untouched", contents)
+	}
+}
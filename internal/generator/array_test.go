@@ -0,0 +1,54 @@
+package generator
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestArrayTypeRoundTrip builds the generated Array type in a module of its
+// own and runs the Scan and Value tests in testdata against it.
+func TestArrayTypeRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a separate module")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+
+	m := newTestManager(t, `{"model_package": "models"}`)
+	err = saveArrayType(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	array, err := m.Files.ReadFile(filepath.Join(m.Config.ModelDir, "array.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests, err := os.ReadFile(filepath.Join("testdata", "array", "array_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{
+		"go.mod":        []byte("module arraytest\n\ngo 1.23\n"),
+		"array.go":      array,
+		"array_test.go": tests,
+	} {
+		err = os.WriteFile(filepath.Join(dir, name), content, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command(goTool, "test", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOWORK=off")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("tests of the Array type failed: %v\n%s", err, output)
+	}
+}
@@ -0,0 +1,163 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+// ArtifactData is passed to user-defined artifact templates and their
+// output path patterns.
+type ArtifactData struct {
+	Entity     string
+	Name       string
+	Snake      string
+	Camel      string
+	LowerCamel string
+	Table      string
+	Action     string
+	Fields     []types.Field
+	Model      ModelData
+	Migration  MigrationData
+
+	// SoftDelete is set for entities with a deleted_at column. NotDeleted
+	// is then the condition query code adds to skip deleted rows, and is
+	// empty otherwise.
+	SoftDelete bool
+	NotDeleted string
+
+	ModulePath      string
+	ModelPackage    string
+	ModelImportPath string
+}
+
+// GenerateArtifacts renders the extra per-entity files declared in the
+// config whose actions include the current one.
+func GenerateArtifacts(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions, cfg *config.Config, fsys files.FS) error {
+	if len(cfg.Artifacts) == 0 {
+		return nil
+	}
+
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+
+	// artifacts describe the whole entity, not only the fields of this run
+	entityFields := fields
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+	if entity := s.Entity(modelName); entity != nil {
+		entityFields = entity.Fields
+	}
+
+	data := ArtifactData{
+		Entity:     entityName,
+		Name:       modelName,
+		Snake:      strcase.ToSnake(modelName),
+		Camel:      modelName,
+		LowerCamel: strcase.ToLowerCamel(modelName),
+		Table:      inflection.Plural(strcase.ToSnake(entityName)),
+		Action:     action.String(),
+		Fields:     entityFields,
+		Model:      prepareModelData(modelName, entityFields, opts, cfg),
+		Migration:  prepareMigrationData(entityName, fields, action, opts, cfg),
+		SoftDelete: opts.SoftDelete,
+
+		ModulePath:      cfg.ModulePath,
+		ModelPackage:    cfg.ModelPackage,
+		ModelImportPath: cfg.ModelImportPath,
+	}
+	data.Model.Package = cfg.ModelPackage
+	if opts.SoftDelete {
+		data.NotDeleted = notDeletedCondition
+	}
+
+	funcMap := template.FuncMap{
+		"toCamel":      strcase.ToCamel,
+		"toLowerCamel": strcase.ToLowerCamel,
+		"toSnake":      strcase.ToSnake,
+		"pluralize":    inflection.Plural,
+		"singularize":  inflection.Singular,
+	}
+
+	for _, artifact := range cfg.Artifacts {
+		if !artifactTriggered(artifact, action) {
+			continue
+		}
+
+		err = renderArtifact(artifact, data, funcMap, fsys)
+		if err != nil {
+			return fmt.Errorf("error generating artifact %s: %w", artifact.Template, err)
+		}
+	}
+
+	return nil
+}
+
+// artifactTriggered reports whether the artifact runs for the action. An
+// artifact without actions is generated when the entity is created.
+func artifactTriggered(artifact config.Artifact, action types.Action) bool {
+	if len(artifact.Actions) == 0 {
+		return action == types.CreateAction
+	}
+	for _, a := range artifact.Actions {
+		if a == action.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func renderArtifact(artifact config.Artifact, data ArtifactData, funcMap template.FuncMap, fsys files.FS) error {
+	pathTmpl, err := template.New("output").Funcs(funcMap).Parse(artifact.Output)
+	if err != nil {
+		return fmt.Errorf("error parsing output pattern %s: %w", artifact.Output, err)
+	}
+	var pathBuf bytes.Buffer
+	err = pathTmpl.Execute(&pathBuf, data)
+	if err != nil {
+		return fmt.Errorf("error executing output pattern %s: %w", artifact.Output, err)
+	}
+	outputPath := pathBuf.String()
+
+	if fsys.Exists(outputPath) && artifact.IfExists != config.ArtifactOverwrite {
+		fmt.Printf("Artifact file exists, skipping: %s\n", outputPath)
+		return nil
+	}
+
+	content, err := fsys.ReadFile(artifact.Template)
+	if err != nil {
+		return fmt.Errorf("error reading template %s: %w", artifact.Template, err)
+	}
+	tmpl, err := template.New(filepath.Base(artifact.Template)).Funcs(funcMap).Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("error parsing template %s: %w", artifact.Template, err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return fmt.Errorf("error executing template %s: %w", artifact.Template, err)
+	}
+
+	err = fsys.MkdirAll(filepath.Dir(outputPath), 0755)
+	if err != nil {
+		return fmt.Errorf("error creating directory for %s: %w", outputPath, err)
+	}
+	err = fsys.WriteFile(outputPath, buf.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing artifact file %s: %w", outputPath, err)
+	}
+
+	fmt.Printf("Artifact file generated: %s\n", outputPath)
+	return nil
+}
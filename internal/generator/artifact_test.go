@@ -0,0 +1,147 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/types"
+)
+
+func TestGenerateArtifacts(t *testing.T) {
+	// .Fields hold the whole entity, .Migration only the columns of the run
+	const template = "{{.Name}} {{.Snake}} {{.Table}} {{.Action}}{{range .Fields}} {{.Name}}{{end}} {{len .Migration.Fields}}"
+
+	tests := []struct {
+		name     string
+		artifact string
+		template string
+		existing string
+		addField bool
+		want     string
+		wantErr  string
+	}{
+		{
+			name:     "create",
+			artifact: `{"template": "service.tmpl", "output": "internal/{{.Snake}}/service.go"}`,
+			template: template,
+			want:     "BlogPost blog_post blog_posts create title 4",
+		},
+		{
+			name:     "not triggered by default",
+			artifact: `{"template": "service.tmpl", "output": "internal/{{.Snake}}/service.go"}`,
+			template: "{{.Action}}",
+			addField: true,
+			want:     "create",
+		},
+		{
+			name:     "triggered action sees all fields",
+			artifact: `{"template": "service.tmpl", "output": "internal/{{.Snake}}/service.go", "actions": ["add_fields"], "if_exists": "overwrite"}`,
+			template: template,
+			addField: true,
+			want:     "BlogPost blog_post blog_posts add_fields title body 1",
+		},
+		{
+			name:     "existing file skipped",
+			artifact: `{"template": "service.tmpl", "output": "internal/{{.Snake}}/service.go"}`,
+			template: template,
+			existing: "hand written",
+			want:     "hand written",
+		},
+		{
+			name:     "existing file overwritten",
+			artifact: `{"template": "service.tmpl", "output": "internal/{{.Snake}}/service.go", "if_exists": "overwrite"}`,
+			template: template,
+			existing: "hand written",
+			want:     "BlogPost blog_post blog_posts create title 4",
+		},
+		{
+			name:     "invalid template",
+			artifact: `{"template": "service.tmpl", "output": "internal/{{.Snake}}/service.go"}`,
+			template: "{{.Missing}}",
+			wantErr:  "error generating artifact",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{"artifacts": [`+tt.artifact+`]}`)
+			dir := filepath.Dir(m.Config.Artifacts[0].Template)
+			err := m.Files.WriteFile(m.Config.Artifacts[0].Template, []byte(tt.template), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := filepath.Join(dir, "internal", "blog_post", "service.go")
+			if tt.existing != "" {
+				err = m.Files.WriteFile(output, []byte(tt.existing), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			fields := []types.Field{{Name: "title", Type: "string"}}
+			err = m.GenerateEntity("blog_post", types.CreateAction, fields, types.EntityOptions{})
+			if err == nil && tt.addField {
+				fields = []types.Field{{Name: "body", Type: "text", IsNullable: true}}
+				err = m.GenerateEntity("blog_post", types.AddFieldsAction, fields, types.EntityOptions{})
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("GenerateEntity() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := m.Files.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("artifact = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArtifactsImportModels(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":         "module example.com/app\n",
+		"codegenex.json": `{"model_dir": "internal/store", "artifacts": [{"template": "repo.tmpl", "output": "internal/{{.Snake}}/repo.go"}]}`,
+		"repo.tmpl":      "package {{.Snake}}\n\nimport {{printf \"%q\" .ModelImportPath}}\n\ntype Repo struct{ items []{{.ModelPackage}}.{{.Name}} }\n",
+	} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg, err := config.Load(filepath.Join(dir, "codegenex.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{Config: cfg, Files: files.NewMemory(files.OS)}
+	generate(t, m, "user", types.CreateAction, types.EntityOptions{}, "name:string")
+
+	model, err := m.Files.ReadFile(getModelFilePath("User", cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(model), "package store\n") {
+		t.Errorf("model is not in package store:\n%s", model)
+	}
+	// config paths are resolved against the directory of the config file
+	repo, err := m.Files.ReadFile(filepath.Join(filepath.Dir(cfg.Artifacts[0].Template), "internal", "user", "repo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package user\n\nimport \"example.com/app/internal/store\"\n\ntype Repo struct{ items []store.User }\n"
+	if string(repo) != want {
+		t.Errorf("artifact = %q, want %q", repo, want)
+	}
+}
@@ -0,0 +1,287 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/parser"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/BurntSushi/toml"
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+	"gopkg.in/yaml.v3"
+)
+
+// batchSpec is the layout of a batch spec file.
+type batchSpec struct {
+	Entities []batchEntity `json:"entities" yaml:"entities" toml:"entities"`
+}
+
+type batchEntity struct {
+	Name       string   `json:"name" yaml:"name" toml:"name"`
+	PrimaryKey string   `json:"primary_key" yaml:"primary_key" toml:"primary_key"`
+	SoftDelete bool     `json:"soft_delete" yaml:"soft_delete" toml:"soft_delete"`
+	Fields     []string `json:"fields" yaml:"fields" toml:"fields"`
+}
+
+// LoadBatchSpec reads the entities of a batch run from a JSON, YAML or TOML
+// file. Fields use the same name:type:options syntax as the command line.
+func LoadBatchSpec(specFile string, fsys files.FS) ([]types.EntitySpec, error) {
+	content, err := fsys.ReadFile(specFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading batch spec %s: %w", specFile, err)
+	}
+
+	var spec batchSpec
+	switch strings.ToLower(filepath.Ext(specFile)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&spec)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&spec)
+	case ".toml":
+		_, err = toml.Decode(string(content), &spec)
+	default:
+		return nil, fmt.Errorf("unsupported batch spec format: %s", specFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing batch spec %s: %w", specFile, err)
+	}
+
+	specs := make([]types.EntitySpec, 0, len(spec.Entities))
+	for _, entity := range spec.Entities {
+		if entity.Name == "" {
+			return nil, fmt.Errorf("error parsing batch spec %s: entity without name", specFile)
+		}
+		fields, err := parser.ParseFields(entity.Fields)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing batch spec %s: entity %s: %w", specFile, entity.Name, err)
+		}
+		specs = append(specs, types.EntitySpec{
+			Name:   entity.Name,
+			Fields: fields,
+			Options: types.EntityOptions{
+				PrimaryKey: entity.PrimaryKey,
+				SoftDelete: entity.SoftDelete,
+			},
+		})
+	}
+	return specs, nil
+}
+
+// GenerateBatch creates several entities with a single migration. The
+// entities are ordered so that referenced tables are created first and
+// dropped last; models, state and artifacts are updated for each of them.
+func GenerateBatch(specs []types.EntitySpec, cfg *config.Config, fsys files.FS) error {
+	if len(specs) == 0 {
+		return fmt.Errorf("no entities to generate")
+	}
+
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	// options first, so references between the new entities resolve to
+	// the primary keys chosen in this batch
+	resolved := make([]types.EntitySpec, len(specs))
+	seen := make(map[string]bool, len(specs))
+	for i, spec := range specs {
+		modelName := inflection.Singular(strcase.ToCamel(spec.Name))
+		if seen[modelName] {
+			return fmt.Errorf("entity %s is listed more than once", modelName)
+		}
+		seen[modelName] = true
+
+		opts, err := resolveOptions(spec.Name, types.CreateAction, spec.Options, s, cfg)
+		if err != nil {
+			return fmt.Errorf("entity %s: %w", spec.Name, err)
+		}
+		resolved[i] = types.EntitySpec{Name: spec.Name, Fields: spec.Fields, Options: opts}
+		s.SetEntity(&state.Entity{
+			Name:       modelName,
+			Table:      inflection.Plural(strcase.ToSnake(spec.Name)),
+			PrimaryKey: opts.PrimaryKey,
+		})
+	}
+	for i, spec := range resolved {
+		err = checkBackfill(types.CreateAction, spec.Fields)
+		if err != nil {
+			return fmt.Errorf("entity %s: %w", spec.Name, err)
+		}
+		resolved[i].Fields, err = resolveFields(spec.Name, spec.Fields, spec.Options, s, cfg)
+		if err != nil {
+			return fmt.Errorf("entity %s: %w", spec.Name, err)
+		}
+	}
+	resolved = sortSpecsByDependency(resolved)
+
+	migrationSQL, err := generateBatchMigration(resolved, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error generating migration: %w", err)
+	}
+
+	names := make([]string, 0, len(resolved))
+	for _, spec := range resolved {
+		names = append(names, spec.Name)
+	}
+	fileName, err := generateMigrationFileName(strings.Join(names, "_"), types.CreateAction, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	err = saveMigrationToFile(migrationSQL, fileName, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error saving migration: %w", err)
+	}
+	fmt.Printf("Migration file generated: %s\n", fileName)
+
+	for _, spec := range resolved {
+		err = GenerateModel(spec.Name, spec.Fields, types.CreateAction, spec.Options, cfg, fsys)
+		if err != nil {
+			return err
+		}
+		err = UpdateState(spec.Name, spec.Fields, types.CreateAction, spec.Options, cfg, fsys)
+		if err != nil {
+			return err
+		}
+		err = GenerateArtifacts(spec.Name, spec.Fields, types.CreateAction, spec.Options, cfg, fsys)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generateBatchMigration renders the create migration of every entity and
+// joins their Up sections in order and their Down sections in reverse. The
+// foreign keys of a reference cycle, which point to a table created later,
+// are added once all tables exist and dropped before any of them.
+func generateBatchMigration(specs []types.EntitySpec, cfg *config.Config, fsys files.FS) (string, error) {
+	batchTables := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		batchTables[inflection.Plural(strcase.ToSnake(spec.Name))] = true
+	}
+
+	ups := make([]string, 0, len(specs))
+	downs := make([]string, 0, len(specs))
+	deferred := make([]MigrationData, 0)
+	created := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		migrationData := prepareMigrationData(spec.Name, spec.Fields, types.CreateAction, spec.Options, cfg)
+		created[migrationData.TableName] = true
+
+		references := make([]ReferenceData, 0, len(migrationData.References))
+		later := make([]ReferenceData, 0)
+		for _, reference := range migrationData.References {
+			if batchTables[reference.RefTable] && !created[reference.RefTable] {
+				later = append(later, reference)
+			} else {
+				references = append(references, reference)
+			}
+		}
+		migrationData.References = references
+		if len(later) > 0 {
+			deferred = append(deferred, MigrationData{TableName: migrationData.TableName, References: later})
+		}
+
+		migrationSQL, err := renderMigration(types.CreateAction.String(), migrationData, cfg, fsys)
+		if err != nil {
+			return "", err
+		}
+		up, down, err := splitMigration(migrationSQL)
+		if err != nil {
+			return "", fmt.Errorf("migration of %s: %w", spec.Name, err)
+		}
+		ups = append(ups, up)
+		downs = append([]string{down}, downs...)
+	}
+
+	for _, migrationData := range deferred {
+		migrationSQL, err := renderMigration(types.AddFieldsAction.String(), migrationData, cfg, fsys)
+		if err != nil {
+			return "", err
+		}
+		up, down, err := splitMigration(migrationSQL)
+		if err != nil {
+			return "", fmt.Errorf("foreign keys of %s: %w", migrationData.TableName, err)
+		}
+		ups = append(ups, up)
+		downs = append([]string{down}, downs...)
+	}
+
+	return "-- +goose Up\n" + strings.Join(ups, "\n\n") + "\n\n-- +goose Down\n" + strings.Join(downs, "\n\n") + "\n", nil
+}
+
+// splitMigration returns the statements of the Up and Down sections of a
+// goose migration without the section annotations.
+func splitMigration(migrationSQL string) (string, string, error) {
+	var up, down []string
+	var section *[]string
+	for _, line := range strings.Split(migrationSQL, "\n") {
+		switch strings.TrimSpace(line) {
+		case "-- +goose Up":
+			section = &up
+			continue
+		case "-- +goose Down":
+			section = &down
+			continue
+		}
+		if section != nil {
+			*section = append(*section, line)
+		}
+	}
+
+	if up == nil || down == nil {
+		return "", "", fmt.Errorf("missing -- +goose Up or -- +goose Down annotation")
+	}
+	return strings.TrimSpace(strings.Join(up, "\n")), strings.TrimSpace(strings.Join(down, "\n")), nil
+}
+
+// sortSpecsByDependency orders the entities so that referenced entities come
+// before the entities referencing them. Entities in a cycle keep their order,
+// generateBatchMigration adds their foreign keys after the tables.
+func sortSpecsByDependency(specs []types.EntitySpec) []types.EntitySpec {
+	byModel := make(map[string]types.EntitySpec, len(specs))
+	for _, spec := range specs {
+		byModel[inflection.Singular(strcase.ToCamel(spec.Name))] = spec
+	}
+
+	sorted := make([]types.EntitySpec, 0, len(specs))
+	visited := make(map[string]bool)
+
+	var visit func(modelName string)
+	visit = func(modelName string) {
+		if visited[modelName] {
+			return
+		}
+		visited[modelName] = true
+		spec := byModel[modelName]
+		for _, field := range spec.Fields {
+			if !field.IsReference {
+				continue
+			}
+			if referenced := referencedModelName(field); referenced != modelName {
+				if _, ok := byModel[referenced]; ok {
+					visit(referenced)
+				}
+			}
+		}
+		sorted = append(sorted, spec)
+	}
+
+	for _, spec := range specs {
+		visit(inflection.Singular(strcase.ToCamel(spec.Name)))
+	}
+	return sorted
+}
@@ -0,0 +1,79 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"codegenex/internal/parser"
+)
+
+func TestGenerateBatchReferenceCycle(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no cycle", args: []string{"--entity=team", "name:string", "--entity=user", "team_id:int:ref"}},
+		{name: "referenced later", args: []string{"--entity=user", "team_id:int:ref", "--entity=team", "name:string"}},
+		{name: "cycle", args: []string{"--entity=team", "user_id:int:ref:null", "--entity=user", "team_id:int:ref:null"}},
+		{name: "cycle of three", args: []string{"--entity=a", "c_id:int:ref:null", "--entity=b", "a_id:int:ref:null", "--entity=c", "b_id:int:ref:null"}},
+		{name: "self reference", args: []string{"--entity=category", "category_id:int:ref:null"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			_, specs, err := parser.ParseBatch(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.GenerateBatch(specs)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			findings, err := m.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, finding := range findings {
+				t.Error(finding)
+			}
+		})
+	}
+}
+
+func TestGenerateBatchMigrationDefersCycleForeignKeys(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	_, specs, err := parser.ParseBatch([]string{"--entity=team", "user_id:int:ref:null", "--entity=user", "team_id:int:ref:null"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs = sortSpecsByDependency(specs)
+
+	migrationSQL, err := generateBatchMigration(specs, m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, down, err := splitMigration(migrationSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both tables exist before the foreign key of the first one is added
+	for _, table := range []string{"teams", "users"} {
+		create := strings.Index(up, "CREATE TABLE IF NOT EXISTS "+table)
+		for _, fk := range []string{"ADD CONSTRAINT fk_teams_user_id", "ADD CONSTRAINT fk_users_team_id"} {
+			if create < 0 || create > strings.Index(up, fk) {
+				t.Errorf("%s is not created before %s:\n%s", table, fk, up)
+			}
+		}
+	}
+	for _, table := range []string{"teams", "users"} {
+		drop := strings.Index(down, "DROP TABLE IF EXISTS "+table)
+		for _, fk := range []string{"DROP CONSTRAINT IF EXISTS fk_teams_user_id", "DROP CONSTRAINT IF EXISTS fk_users_team_id"} {
+			if drop < 0 || drop < strings.Index(down, fk) {
+				t.Errorf("%s is not dropped after %s:\n%s", table, fk, down)
+			}
+		}
+	}
+}
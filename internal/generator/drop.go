@@ -0,0 +1,315 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+// prepareDrop returns the fields the Down section of a drop migration
+// recreates, the recorded ones unless fields are given, and warns about
+// foreign keys of other tables that keep the table from being dropped.
+func prepareDrop(entityName string, fields []types.Field, s *state.State) []types.Field {
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+	tableName := inflection.Plural(strcase.ToSnake(entityName))
+
+	for _, entity := range s.Entities {
+		if entity.Name == modelName {
+			continue
+		}
+		for _, field := range entity.Fields {
+			if field.IsReference && referencedModelName(field) == modelName {
+				fmt.Printf("Warning: %s.%s references %s through fk_%s_%s, remove the field first or the drop migration fails\n",
+					entity.Table, field.Name, tableName, entity.Table, field.Name)
+			}
+		}
+	}
+
+	if len(fields) > 0 {
+		return fields
+	}
+	entity := s.Entity(modelName)
+	if entity == nil {
+		fmt.Printf("Warning: entity %s is missing from the state, the Down section recreates %s without its fields\n", modelName, tableName)
+		return fields
+	}
+	return entity.Fields
+}
+
+// RemoveModel deletes the model file of the entity and cleans up the rest
+// of the model directory so it still compiles: relation fields to the model
+// and the declarations of its enum types are removed, fields of those enum
+// types become plain strings.
+func RemoveModel(entityName string, cfg *config.Config, fsys files.FS) error {
+	return removeModel(inflection.Singular(strcase.ToCamel(entityName)), cfg, fsys)
+}
+
+func removeModel(modelName string, cfg *config.Config, fsys files.FS) error {
+	enumTypes, err := modelEnumTypes(modelName, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	filePath := getModelFilePath(modelName, cfg)
+	if fsys.Exists(filePath) {
+		err = fsys.Remove(filePath)
+		if err != nil {
+			return fmt.Errorf("error removing model file %s: %w", filePath, err)
+		}
+		fmt.Printf("Model file removed: %s\n", filePath)
+	} else {
+		fmt.Printf("Warning: model file %s does not exist\n", filePath)
+	}
+
+	return removeModelReferences(modelName, enumTypes, cfg, fsys)
+}
+
+// modelEnumTypes returns the enum types of the model, as recorded in the
+// state and as declared next to the model in its file.
+func modelEnumTypes(modelName string, cfg *config.Config, fsys files.FS) (map[string]bool, error) {
+	enumTypes := make(map[string]bool)
+
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	if entity := s.Entity(modelName); entity != nil {
+		for _, field := range entity.Fields {
+			if field.IsEnum {
+				enumTypes[modelEnumType(modelName, field.Name)] = true
+			}
+		}
+	}
+
+	filePath := getModelFilePath(modelName, cfg)
+	if !fsys.Exists(filePath) {
+		return enumTypes, nil
+	}
+	node, err := parseGoFile(token.NewFileSet(), filePath, fsys)
+	if err != nil {
+		return nil, err
+	}
+	for typeName := range collectEnumValues(node) {
+		enumTypes[typeName] = true
+	}
+	return enumTypes, nil
+}
+
+// removeModelReferences removes what points to a dropped model from the
+// other files of the model directory.
+func removeModelReferences(modelName string, enumTypes map[string]bool, cfg *config.Config, fsys files.FS) error {
+	names, err := fsys.ReadDir(cfg.ModelDir)
+	if err != nil {
+		return fmt.Errorf("error reading model directory %s: %w", cfg.ModelDir, err)
+	}
+
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		filePath := filepath.Join(cfg.ModelDir, name)
+
+		fset := token.NewFileSet()
+		node, err := parseGoFile(fset, filePath, fsys)
+		if err != nil {
+			return err
+		}
+		if !removeReferencesFromFile(fset, node, modelName, enumTypes) {
+			continue
+		}
+
+		var buf bytes.Buffer
+		err = format.Node(&buf, fset, node)
+		if err != nil {
+			return fmt.Errorf("error formatting updated file: %w", err)
+		}
+		err = fsys.WriteFile(filePath, buf.Bytes(), 0644)
+		if err != nil {
+			return fmt.Errorf("error writing model file: %w", err)
+		}
+		fmt.Printf("Model file updated: %s\n", filePath)
+	}
+	return nil
+}
+
+// removeReferencesFromFile removes the relation fields to the model and the
+// declarations of its enum types from the file. It reports whether anything
+// changed.
+func removeReferencesFromFile(fset *token.FileSet, node *ast.File, modelName string, enumTypes map[string]bool) bool {
+	changed := removeEnumDecls(node, enumTypes)
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			if ts, ok := spec.(*ast.TypeSpec); ok {
+				if structType, ok := ts.Type.(*ast.StructType); ok {
+					fields := structType.Fields.List
+					if removeRelationFields(structType, modelName, enumTypes) {
+						mergeRemovedLines(fset, fields, structType.Fields.List)
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return changed
+}
+
+// removeEnumDecls removes the declarations of the enum types, their
+// constants, methods and Valid functions together with their comments. It
+// reports whether anything was removed.
+func removeEnumDecls(node *ast.File, enumTypes map[string]bool) bool {
+	removed := make([]ast.Node, 0)
+
+	decls := make([]ast.Decl, 0, len(node.Decls))
+	for _, decl := range node.Decls {
+		switch decl := decl.(type) {
+		case *ast.GenDecl:
+			specs := make([]ast.Spec, 0, len(decl.Specs))
+			for _, spec := range decl.Specs {
+				if isEnumSpec(spec, enumTypes) {
+					removed = append(removed, spec)
+					continue
+				}
+				specs = append(specs, spec)
+			}
+			if len(specs) == 0 {
+				removed = append(removed, decl)
+				continue
+			}
+			decl.Specs = specs
+		case *ast.FuncDecl:
+			if isEnumFunc(decl, enumTypes) {
+				removed = append(removed, decl)
+				continue
+			}
+		}
+		decls = append(decls, decl)
+	}
+	node.Decls = decls
+
+	comments := make([]*ast.CommentGroup, 0, len(node.Comments))
+	for _, comment := range node.Comments {
+		if !withinNodes(comment, removed) {
+			comments = append(comments, comment)
+		}
+	}
+	node.Comments = comments
+
+	return len(removed) > 0
+}
+
+// removeRelationFields removes the *Model and []*Model fields of the struct
+// and turns fields of the enum types into strings.
+func removeRelationFields(structType *ast.StructType, modelName string, enumTypes map[string]bool) bool {
+	changed := false
+	fields := make([]*ast.Field, 0, len(structType.Fields.List))
+	for _, field := range structType.Fields.List {
+		if pointerTypeName(field.Type) == modelName {
+			changed = true
+			continue
+		}
+		if ident, ok := field.Type.(*ast.Ident); ok && enumTypes[ident.Name] {
+			field.Type = ast.NewIdent("string")
+			changed = true
+		}
+		fields = append(fields, field)
+	}
+	structType.Fields.List = fields
+	return changed
+}
+
+// mergeRemovedLines joins the lines of the fields removed from a struct with
+// the lines that follow them, so the printer leaves no blank lines behind.
+func mergeRemovedLines(fset *token.FileSet, before, after []*ast.Field) {
+	kept := make(map[*ast.Field]bool, len(after))
+	for _, field := range after {
+		kept[field] = true
+	}
+	// later lines first, merging shifts the lines that follow
+	for i := len(before) - 1; i >= 0; i-- {
+		field := before[i]
+		if kept[field] || !field.Pos().IsValid() {
+			continue
+		}
+		file := fset.File(field.Pos())
+		for line := file.Line(field.End()); line >= file.Line(field.Pos()); line-- {
+			file.MergeLine(line)
+		}
+	}
+}
+
+// isEnumSpec reports whether the spec declares one of the enum types or a
+// constant of one.
+func isEnumSpec(spec ast.Spec, enumTypes map[string]bool) bool {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return enumTypes[spec.Name.Name]
+	case *ast.ValueSpec:
+		ident, ok := spec.Type.(*ast.Ident)
+		return ok && enumTypes[ident.Name]
+	}
+	return false
+}
+
+// isEnumFunc reports whether the function is a method of one of the enum
+// types or its Valid<Type> function.
+func isEnumFunc(decl *ast.FuncDecl, enumTypes map[string]bool) bool {
+	if decl.Recv == nil {
+		return strings.HasPrefix(decl.Name.Name, "Valid") && enumTypes[strings.TrimPrefix(decl.Name.Name, "Valid")]
+	}
+	for _, recv := range decl.Recv.List {
+		recvType := recv.Type
+		if star, ok := recvType.(*ast.StarExpr); ok {
+			recvType = star.X
+		}
+		if ident, ok := recvType.(*ast.Ident); ok && enumTypes[ident.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// withinNodes reports whether the comment belongs to one of the nodes,
+// including their doc comments.
+func withinNodes(comment *ast.CommentGroup, nodes []ast.Node) bool {
+	for _, node := range nodes {
+		start := node.Pos()
+		switch node := node.(type) {
+		case *ast.GenDecl:
+			if node.Doc != nil {
+				start = node.Doc.Pos()
+			}
+		case *ast.FuncDecl:
+			if node.Doc != nil {
+				start = node.Doc.Pos()
+			}
+		case *ast.TypeSpec:
+			if node.Doc != nil {
+				start = node.Doc.Pos()
+			}
+		case *ast.ValueSpec:
+			if node.Doc != nil {
+				start = node.Doc.Pos()
+			}
+		}
+		if comment.Pos() >= start && comment.End() <= node.End() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codegenex/internal/state"
+	gentypes "codegenex/internal/types"
+)
+
+// captureStdout returns what fn prints to the standard output.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan string)
+	go func() {
+		output, _ := io.ReadAll(r)
+		done <- string(output)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+// checkModels type-checks the package in the model directory.
+func checkModels(t *testing.T, m *Manager) {
+	t.Helper()
+	names, err := m.Files.ReadDir(m.Config.ModelDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	parsed := make([]*ast.File, 0, len(names))
+	for _, name := range names {
+		content, err := m.Files.ReadFile(filepath.Join(m.Config.ModelDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		file, err := parser.ParseFile(fset, name, content, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, file)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	_, err = conf.Check(m.Config.ModelPackage, fset, parsed, nil)
+	if err != nil {
+		t.Errorf("models do not compile: %v", err)
+	}
+}
+
+func TestPrepareDrop(t *testing.T) {
+	s := &state.State{Entities: []*state.Entity{
+		{Name: "User", Table: "users", Fields: []gentypes.Field{{Name: "email", Type: "string"}}},
+		{Name: "Post", Table: "posts", Fields: []gentypes.Field{{Name: "user_id", Type: "int", IsReference: true}}},
+		{Name: "Tag", Table: "tags"},
+	}}
+
+	tests := []struct {
+		name        string
+		entity      string
+		fields      []gentypes.Field
+		want        []gentypes.Field
+		wantWarning string
+	}{
+		{
+			name:        "recorded fields",
+			entity:      "users",
+			want:        []gentypes.Field{{Name: "email", Type: "string"}},
+			wantWarning: "Warning: posts.user_id references users through fk_posts_user_id, remove the field first or the drop migration fails\n",
+		},
+		{
+			name:   "given fields",
+			entity: "post",
+			fields: []gentypes.Field{{Name: "title", Type: "string"}},
+			want:   []gentypes.Field{{Name: "title", Type: "string"}},
+		},
+		{
+			name:        "missing entity",
+			entity:      "comment",
+			wantWarning: "Warning: entity Comment is missing from the state, the Down section recreates comments without its fields\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []gentypes.Field
+			output := captureStdout(t, func() {
+				got = prepareDrop(tt.entity, tt.fields, s)
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prepareDrop() = %+v, want %+v", got, tt.want)
+			}
+			if output != tt.wantWarning {
+				t.Errorf("prepareDrop() printed %q, want %q", output, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestDropRemovesModel(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "user", gentypes.CreateAction, gentypes.EntityOptions{}, "email:string", "role:enum[admin,member]")
+	generate(t, m, "post", gentypes.CreateAction, gentypes.EntityOptions{}, "title:string")
+	generate(t, m, "post", gentypes.AddFieldsAction, gentypes.EntityOptions{}, "user_id:int:ref:null")
+
+	// a hand written model that uses the user model and its enum type
+	audit := filepath.Join(m.Config.ModelDir, "audit.go")
+	err := m.Files.WriteFile(audit, []byte("package models\n\ntype Audit struct {\n\tRole   UserRoleType\n\tAuthor *User\n\tNote   string\n}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkModels(t, m)
+
+	output := captureStdout(t, func() {
+		generate(t, m, "user", gentypes.DropAction, gentypes.EntityOptions{})
+	})
+	if !strings.Contains(output, "Warning: posts.user_id references users") {
+		t.Errorf("drop does not warn about the foreign key of posts:\n%s", output)
+	}
+
+	if m.Files.Exists(getModelFilePath("User", m.Config)) {
+		t.Error("model file of User still exists")
+	}
+	post, err := m.Files.ReadFile(getModelFilePath("Post", m.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(post), "*User") {
+		t.Errorf("post model still has a relation to User:\n%s", post)
+	}
+	content, err := m.Files.ReadFile(audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "package models\n\ntype Audit struct {\n\tRole string\n\tNote string\n}\n"
+	if string(content) != want {
+		t.Errorf("audit model = %q, want %q", content, want)
+	}
+	checkModels(t, m)
+
+	if loadTestState(t, m).Entity("User") != nil {
+		t.Error("User is still in the state")
+	}
+
+	// the Down section recreates the table from the recorded fields
+	migrations, err := listMigrations(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migration, err := m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, migrations[len(migrations)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, down, err := splitMigration(string(migration))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"CREATE TYPE users_roles AS ENUM", "email VARCHAR(255) NOT NULL", "role users_roles NOT NULL"} {
+		if !strings.Contains(down, want) {
+			t.Errorf("down section lacks %q:\n%s", want, down)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+// ExpandData describes a column change for the expand, backfill and
+// contract migrations.
+type ExpandData struct {
+	TableName  string
+	Column     string
+	NewColumn  string
+	SQLType    string
+	OldSQLType string
+	NotNull    bool
+	Default    string
+	// ChangeType casts between the columns and gives the new column the
+	// name of the old one when contracting.
+	ChangeType bool
+	Trigger    string
+	BatchSize  int
+	// Indexes are built on the new column. A type change renames them to
+	// FinalIndexes, in the same order, when contracting.
+	Indexes      []IndexData
+	FinalIndexes []IndexData
+	// OldIndexes and OldConstraint are what the old column had, restored
+	// by the Down section of the contract migration.
+	OldIndexes    []IndexData
+	OldConstraint string
+}
+
+// RenameField starts renaming a column: the expand phase adds the new
+// column, keeps both columns in sync with a trigger and backfills the new
+// one. The old column is dropped by Contract.
+func RenameField(entityName, oldName, newName string, cfg *config.Config, fsys files.FS) error {
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+
+	s, entity, err := loadChangedEntity(modelName, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	field, err := changedField(entity, oldName, s)
+	if err != nil {
+		return err
+	}
+	if entity.Field(newName) != nil || isImplicitColumn(newName) {
+		return fmt.Errorf("entity %s already has a field %s", modelName, newName)
+	}
+	if changeOfColumn(s, modelName, newName) != nil {
+		return fmt.Errorf("field %s of entity %s is used by a pending change", newName, modelName)
+	}
+
+	change := &state.Change{
+		Entity:    modelName,
+		Kind:      string(types.RenameFieldAction),
+		Column:    oldName,
+		NewColumn: newName,
+		Phase:     state.PhaseExpand,
+	}
+	err = saveExpandMigrations(entityName, change, entity, field, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	// new code can use the new field right away, the old one stays until
+	// the contract phase
+	newField := *field
+	newField.Name = newName
+	newField.UniqueIndex = field.IsUnique
+	err = addFieldsToModel(modelName, []types.Field{newField}, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	entity.AddFields([]types.Field{newField})
+
+	s.Changes = append(s.Changes, change)
+	return s.Save(fsys, cfg.StateFile)
+}
+
+// ChangeFieldType starts changing the type of a column. The expand phase
+// adds a column of the new type next to it, kept in sync and backfilled;
+// Contract drops the old column and gives the new one its name.
+func ChangeFieldType(entityName string, newField types.Field, cfg *config.Config, fsys files.FS) error {
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+
+	s, entity, err := loadChangedEntity(modelName, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	field, err := changedField(entity, newField.Name, s)
+	if err != nil {
+		return err
+	}
+	if newField.IsEnum || newField.IsReference {
+		return fmt.Errorf("field %s cannot be changed to an enum or reference", newField.Name)
+	}
+	err = validateFieldTypes([]types.Field{newField}, cfg)
+	if err != nil {
+		return err
+	}
+	if getSQLType(newField, cfg) == columnSQLType(entity.Table, *field, cfg) {
+		return fmt.Errorf("field %s of entity %s already has type %s", newField.Name, modelName, newField.Type)
+	}
+
+	newColumn := newField.Name + "_new"
+	if entity.Field(newColumn) != nil {
+		return fmt.Errorf("entity %s already has a field %s", modelName, newColumn)
+	}
+
+	change := &state.Change{
+		Entity:    modelName,
+		Kind:      string(types.ChangeTypeAction),
+		Column:    newField.Name,
+		NewColumn: newColumn,
+		Type:      newField.Type,
+		Phase:     state.PhaseExpand,
+	}
+	err = saveExpandMigrations(entityName, change, entity, field, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	s.Changes = append(s.Changes, change)
+	return s.Save(fsys, cfg.StateFile)
+}
+
+// Contract generates the contract migrations of the pending changes of
+// the entity, or of all entities when entityName is empty, and updates the
+// models and the state to the final columns.
+func Contract(entityName string, cfg *config.Config, fsys files.FS) error {
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	modelName := ""
+	if entityName != "" {
+		modelName = inflection.Singular(strcase.ToCamel(entityName))
+	}
+	pending := s.PendingChanges(modelName)
+	if len(pending) == 0 {
+		fmt.Println("No pending changes to contract.")
+		return nil
+	}
+
+	for _, change := range pending {
+		entity := s.Entity(change.Entity)
+		if entity == nil {
+			return fmt.Errorf("entity %s is missing from %s, run `codegenex state from-models` to rebuild it", change.Entity, cfg.StateFile)
+		}
+		field := entity.Field(change.Column)
+		if field == nil {
+			return fmt.Errorf("field %s of entity %s is missing from %s", change.Column, change.Entity, cfg.StateFile)
+		}
+
+		data := prepareExpandData(change, entity, *field, cfg)
+		err = saveExpandMigration(fmt.Sprintf("contract_%s_%s", entity.Table, change.Column), "contract", data, cfg, fsys)
+		if err != nil {
+			return err
+		}
+
+		if change.Kind == string(types.ChangeTypeAction) {
+			field.Type = change.Type
+			field.IsEnum = false
+			field.EnumValues = nil
+			field.UniqueIndex = field.IsUnique
+			err = setModelFieldType(change.Entity, *field, cfg, fsys)
+		} else {
+			entity.RemoveFields([]types.Field{*field})
+			err = removeFieldsFromModel(change.Entity, []types.Field{{Name: change.Column}}, cfg, fsys)
+		}
+		if err != nil {
+			return err
+		}
+		change.Phase = state.PhaseContract
+	}
+
+	return s.Save(fsys, cfg.StateFile)
+}
+
+func loadChangedEntity(modelName string, cfg *config.Config, fsys files.FS) (*state.State, *state.Entity, error) {
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	entity := s.Entity(modelName)
+	if entity == nil {
+		return nil, nil, fmt.Errorf("entity %s is missing from %s, run `codegenex state from-models` to rebuild it", modelName, cfg.StateFile)
+	}
+	return s, entity, nil
+}
+
+// changedField returns the field a new change applies to. Fields with a
+// pending change and reference fields cannot be changed in phases.
+func changedField(entity *state.Entity, name string, s *state.State) (*types.Field, error) {
+	field := entity.Field(name)
+	if field == nil {
+		return nil, fmt.Errorf("entity %s has no field %s", entity.Name, name)
+	}
+	if field.IsReference {
+		return nil, fmt.Errorf("reference field %s cannot be changed in phases", name)
+	}
+	if changeOfColumn(s, entity.Name, name) != nil {
+		return nil, fmt.Errorf("field %s of entity %s already has a pending change, contract it first", name, entity.Name)
+	}
+	return field, nil
+}
+
+// changeOfColumn returns the pending change that uses the column as its
+// old or new column.
+func changeOfColumn(s *state.State, modelName, column string) *state.Change {
+	for _, change := range s.PendingChanges(modelName) {
+		if change.Column == column || change.NewColumn == column {
+			return change
+		}
+	}
+	return nil
+}
+
+func isImplicitColumn(name string) bool {
+	switch name {
+	case "id", "created_at", "updated_at", "deleted_at":
+		return true
+	}
+	return false
+}
+
+func saveExpandMigrations(entityName string, change *state.Change, entity *state.Entity, field *types.Field, cfg *config.Config, fsys files.FS) error {
+	data := prepareExpandData(change, entity, *field, cfg)
+
+	err := saveExpandMigration(fmt.Sprintf("expand_%s_%s", entity.Table, change.Column), "expand", data, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	return saveExpandMigration(fmt.Sprintf("backfill_%s_%s", entity.Table, change.NewColumn), "backfill", data, cfg, fsys)
+}
+
+func prepareExpandData(change *state.Change, entity *state.Entity, field types.Field, cfg *config.Config) ExpandData {
+	newField := field
+	newField.Name = change.NewColumn
+	if change.Kind == string(types.ChangeTypeAction) {
+		newField.Type = change.Type
+		newField.IsEnum = false
+		newField.EnumValues = nil
+	}
+
+	data := ExpandData{
+		TableName:    entity.Table,
+		Column:       change.Column,
+		NewColumn:    change.NewColumn,
+		SQLType:      columnSQLType(entity.Table, newField, cfg),
+		OldSQLType:   columnSQLType(entity.Table, field, cfg),
+		NotNull:      !field.IsNullable,
+		Default:      field.DefaultValue,
+		ChangeType:   change.Kind == string(types.ChangeTypeAction),
+		Trigger:      fmt.Sprintf("sync_%s_%s", entity.Table, change.Column),
+		BatchSize:    cfg.BackfillBatchSize,
+		Indexes:      columnIndexes(entity.Table, change.NewColumn, field, entity.SoftDelete),
+		FinalIndexes: columnIndexes(entity.Table, change.Column, field, entity.SoftDelete),
+	}
+	data.OldIndexes, data.OldConstraint = existingColumnIndexes(entity.Table, field, entity.SoftDelete)
+	return data
+}
+
+// columnSQLType is the column type of a field, with enums named as in the
+// migrations that created them.
+func columnSQLType(tableName string, field types.Field, cfg *config.Config) string {
+	if field.IsEnum {
+		return enumTypeName(tableName, field)
+	}
+	return getSQLType(field, cfg)
+}
+
+// columnIndexes returns the indexes the field has on the given column. A
+// unique field is covered by a unique index since the column of a phased
+// change is filled before it can be constrained.
+func columnIndexes(tableName, column string, field types.Field, softDelete bool) []IndexData {
+	indexes := make([]IndexData, 0, 1)
+	switch {
+	case field.IsUnique:
+		index := IndexData{
+			Name:    fmt.Sprintf("uniq_%s_%s", tableName, column),
+			Columns: []string{column},
+			Unique:  true,
+		}
+		if softDelete {
+			index.Where = notDeletedCondition
+		}
+		indexes = append(indexes, index)
+	case field.IsIndex:
+		indexes = append(indexes, IndexData{
+			Name:    fmt.Sprintf("idx_%s_%s", tableName, column),
+			Columns: []string{column},
+			Method:  indexMethod(field),
+		})
+	}
+	return indexes
+}
+
+// existingColumnIndexes returns the indexes and the name of the unique
+// constraint the column of a field has, as recorded in the state.
+func existingColumnIndexes(tableName string, field types.Field, softDelete bool) ([]IndexData, string) {
+	// soft delete tables and earlier phased changes cover unique fields
+	// with a unique index
+	if field.IsUnique && (softDelete || field.UniqueIndex) {
+		return columnIndexes(tableName, field.Name, field, softDelete), ""
+	}
+
+	indexes := make([]IndexData, 0, 1)
+	if field.IsIndex {
+		indexes = append(indexes, IndexData{
+			Name:    fmt.Sprintf("idx_%s_%s", tableName, field.Name),
+			Columns: []string{field.Name},
+			Method:  indexMethod(field),
+		})
+	}
+	constraint := ""
+	if field.IsUnique {
+		constraint = fmt.Sprintf("%s_%s_key", tableName, field.Name)
+	}
+	return indexes, constraint
+}
+
+func saveExpandMigration(name, templateName string, data ExpandData, cfg *config.Config, fsys files.FS) error {
+	funcMap := template.FuncMap{
+		"toSnake": strcase.ToSnake,
+	}
+	tmpl, err := loadTemplate("migrations/"+templateName+".tmpl", funcMap, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return fmt.Errorf("error executing migration template: %w", err)
+	}
+
+	fileName, err := newMigrationFileName(name, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	err = saveMigrationToFile(buf.String(), fileName, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error saving migration: %w", err)
+	}
+	fmt.Printf("Migration file generated: %s\n", fileName)
+	return nil
+}
@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codegenex/internal/types"
+)
+
+func TestContractSetsNotNullWithValidatedCheck(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "user", types.CreateAction, types.EntityOptions{}, "name:string")
+	err := m.RenameField("user", "name", "full_name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.Contract("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := listMigrations(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, migrations[len(migrations)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, _, err := splitMigration(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// SET NOT NULL finds the validated check and skips scanning the table
+	// under its lock
+	steps := []string{
+		"ADD CONSTRAINT chk_users_full_name_not_null CHECK (full_name IS NOT NULL) NOT VALID;",
+		"VALIDATE CONSTRAINT chk_users_full_name_not_null;",
+		"-- +goose StatementBegin",
+		"ALTER COLUMN full_name SET NOT NULL;",
+		"DROP CONSTRAINT chk_users_full_name_not_null;",
+	}
+	position := -1
+	for _, step := range steps {
+		next := strings.Index(up, step)
+		if next <= position {
+			t.Fatalf("%q missing or out of order in the contract migration:\n%s", step, up)
+		}
+		position = next
+	}
+	if !strings.HasPrefix(string(content), "-- +goose NO TRANSACTION") {
+		t.Errorf("contract migration runs in a transaction, VALIDATE CONSTRAINT would hold the lock of ADD CONSTRAINT")
+	}
+
+	findings, err := m.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finding := range findings {
+		t.Error(finding)
+	}
+}
+
+func TestPhasedChangesOfUniqueField(t *testing.T) {
+	tests := []struct {
+		name       string
+		softDelete bool
+		field      string
+	}{
+		{name: "unique", field: "email:string:unique"},
+		{name: "unique and indexed", field: "email:string:unique:i"},
+		{name: "soft delete", softDelete: true, field: "email:string:unique"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			generate(t, m, "user", types.CreateAction, types.EntityOptions{SoftDelete: tt.softDelete}, "name:string", tt.field)
+
+			err := m.RenameField("user", "email", "mail")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.Contract("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.RenameField("user", "mail", "address")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.Contract("")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			field := loadTestState(t, m).Entity("User").Field("address")
+			if field == nil || !field.IsUnique || !field.UniqueIndex {
+				t.Fatalf("address = %+v, want a unique field covered by a unique index", field)
+			}
+			err = m.BuildStateFromMigrations(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if field := loadTestState(t, m).Entity("User").Field("address"); field == nil || !field.UniqueIndex {
+				t.Fatalf("address = %+v after replaying the migrations, want a unique index", field)
+			}
+
+			// migrations restoring the column restore its index as well
+			migrationData := prepareMigrationData("user", []types.Field{*field}, types.AddFieldsAction, types.EntityOptions{SoftDelete: tt.softDelete}, m.Config)
+			if migrationData.Fields[0].IsUnique {
+				t.Errorf("address is restored with a unique constraint")
+			}
+			found := false
+			for _, index := range migrationData.Indexes {
+				if index.Name == "uniq_users_address" && index.Unique {
+					found = true
+					if (index.Where != "") != tt.softDelete {
+						t.Errorf("uniq_users_address has condition %q, want it only for soft delete", index.Where)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("indexes = %+v, want uniq_users_address", migrationData.Indexes)
+			}
+
+			// the Down of remove_fields restores the index as well
+			generate(t, m, "user", types.RemoveFieldsAction, types.EntityOptions{}, "address")
+
+			findings, err := m.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, finding := range findings {
+				t.Error(finding)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/types"
+)
+
+// fieldType describes how a field type maps onto a column and a model field.
+type fieldType struct {
+	// SQLType is the column type in migrations.
+	SQLType string
+	// GoType is the type of the model field.
+	GoType string
+	// Imports are the packages the Go type needs.
+	Imports []string
+	// Default, Check and Setup come from project types, see
+	// config.TypeConfig.
+	Default string
+	Check   string
+	Setup   string
+}
+
+var fieldTypes = map[string]fieldType{
+	"int":         {SQLType: "INTEGER", GoType: "int64"},
+	"bigint":      {SQLType: "BIGINT", GoType: "int64"},
+	"smallint":    {SQLType: "SMALLINT", GoType: "int16"},
+	"uuid":        {SQLType: "UUID", GoType: "string"},
+	"ulid":        {SQLType: "CHAR(26)", GoType: "string"},
+	"string":      {SQLType: "VARCHAR(255)", GoType: "string"},
+	"text":        {SQLType: "TEXT", GoType: "string"},
+	"citext":      {SQLType: "CITEXT", GoType: "string"},
+	"bool":        {SQLType: "BOOLEAN", GoType: "bool"},
+	"time":        {SQLType: "TIMESTAMP", GoType: "time.Time", Imports: []string{"time"}},
+	"timestamptz": {SQLType: "TIMESTAMPTZ", GoType: "time.Time", Imports: []string{"time"}},
+	"date":        {SQLType: "DATE", GoType: "time.Time", Imports: []string{"time"}},
+	"interval":    {SQLType: "INTERVAL", GoType: "string"},
+	"float":       {SQLType: "NUMERIC", GoType: "float64"},
+	// decimal keeps the exact value as a string, float64 would round it
+	"decimal": {SQLType: "NUMERIC", GoType: "string"},
+	"bytea":   {SQLType: "BYTEA", GoType: "[]byte"},
+	"inet":    {SQLType: "INET", GoType: "string"},
+	"json":    {SQLType: "JSON", GoType: "json.RawMessage", Imports: []string{"encoding/json"}},
+	"jsonb":   {SQLType: "JSONB", GoType: "map[string]interface{}"},
+}
+
+// lookupFieldType returns the mapping of a field type, a project type of
+// the config or a built-in one. varchar(n) and decimal(p,s) carry their
+// modifiers into the column type, and type[] is an array of type.
+func lookupFieldType(name string, cfg *config.Config) (fieldType, error) {
+	if strings.HasSuffix(name, "[]") {
+		element, err := lookupFieldType(strings.TrimSuffix(name, "[]"), cfg)
+		if err != nil {
+			return fieldType{}, err
+		}
+		return arrayFieldType(element, cfg)
+	}
+
+	if t, ok := cfg.Types[name]; ok {
+		imports := make([]string, 0, 1)
+		if t.Import != "" {
+			imports = append(imports, t.Import)
+		}
+		return fieldType{
+			SQLType: t.SQLType(),
+			GoType:  t.Go,
+			Imports: imports,
+			Default: t.Default,
+			Check:   t.Check,
+			Setup:   t.Setup,
+		}, nil
+	}
+
+	base, modifiers, err := splitTypeModifiers(name)
+	if err != nil {
+		return fieldType{}, err
+	}
+
+	switch base {
+	case "varchar":
+		if len(modifiers) != 1 || modifiers[0] < 1 {
+			return fieldType{}, fmt.Errorf("invalid type %q, expected varchar(n)", name)
+		}
+		return fieldType{SQLType: fmt.Sprintf("VARCHAR(%d)", modifiers[0]), GoType: "string"}, nil
+	case "decimal":
+		if len(modifiers) == 0 {
+			break
+		}
+		if len(modifiers) > 2 || modifiers[0] < 1 || modifiers[0] > 1000 || (len(modifiers) == 2 && modifiers[1] > modifiers[0]) {
+			return fieldType{}, fmt.Errorf("invalid type %q, expected decimal(precision,scale)", name)
+		}
+		t := fieldTypes["decimal"]
+		t.SQLType = fmt.Sprintf("NUMERIC(%s)", joinInts(modifiers))
+		return t, nil
+	default:
+		if len(modifiers) > 0 {
+			return fieldType{}, fmt.Errorf("type %s takes no modifiers", base)
+		}
+	}
+
+	t, ok := fieldTypes[base]
+	if !ok {
+		if base == "" {
+			return fieldType{}, fmt.Errorf("missing type")
+		}
+		return fieldType{}, fmt.Errorf("unknown type %q, expected one of %s", name, strings.Join(fieldTypeNames(cfg), ", "))
+	}
+	return t, nil
+}
+
+const (
+	pqImport     = "github.com/lib/pq"
+	pgtypeImport = "github.com/jackc/pgx/v5/pgtype"
+)
+
+// pqArrayTypes are the lib/pq array types by element Go type.
+var pqArrayTypes = map[string]string{
+	"string":  "pq.StringArray",
+	"int64":   "pq.Int64Array",
+	"float64": "pq.Float64Array",
+	"bool":    "pq.BoolArray",
+	"[]byte":  "pq.ByteaArray",
+}
+
+// qualifiedPattern finds the package names in a Go type such as
+// Array[decimal.Decimal].
+var qualifiedPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\.`)
+
+// arrayFieldType maps an array of the element type onto the Go array
+// representation chosen in the config. Defaults and checks of the element
+// do not apply to the array.
+func arrayFieldType(element fieldType, cfg *config.Config) (fieldType, error) {
+	t := fieldType{SQLType: element.SQLType + "[]", Setup: element.Setup}
+	switch cfg.ArrayType {
+	case config.ArrayPq:
+		goType, ok := pqArrayTypes[element.GoType]
+		if !ok {
+			return fieldType{}, fmt.Errorf("lib/pq has no array type for %s elements, use array_type %s or %s", element.GoType, config.ArrayGeneric, config.ArrayPgtype)
+		}
+		t.GoType = goType
+		t.Imports = []string{pqImport}
+	case config.ArrayPgtype:
+		t.GoType = "pgtype.FlatArray[" + element.GoType + "]"
+		t.Imports = append([]string{pgtypeImport}, element.Imports...)
+	default:
+		t.GoType = "Array[" + element.GoType + "]"
+		t.Imports = element.Imports
+	}
+	return t, nil
+}
+
+// validateFieldTypes checks that every field has a known type. Enum fields
+// carry their values instead.
+func validateFieldTypes(fields []types.Field, cfg *config.Config) error {
+	for _, field := range fields {
+		if field.IsEnum {
+			continue
+		}
+		_, err := lookupFieldType(field.Type, cfg)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// splitTypeModifiers splits decimal(10,2) into decimal and [10 2].
+func splitTypeModifiers(name string) (string, []int, error) {
+	open := strings.Index(name, "(")
+	if open < 0 {
+		return name, nil, nil
+	}
+	if !strings.HasSuffix(name, ")") {
+		return "", nil, fmt.Errorf("invalid type %q", name)
+	}
+
+	modifiers := make([]int, 0, 2)
+	for _, part := range strings.Split(name[open+1:len(name)-1], ",") {
+		value, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || value < 0 {
+			return "", nil, fmt.Errorf("invalid modifier %q of type %q", part, name)
+		}
+		modifiers = append(modifiers, value)
+	}
+	return name[:open], modifiers, nil
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, strconv.Itoa(value))
+	}
+	return strings.Join(parts, ",")
+}
+
+func fieldTypeNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(fieldTypes)+len(cfg.Types)+2)
+	for name := range fieldTypes {
+		if _, ok := cfg.Types[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for name := range cfg.Types {
+		names = append(names, name)
+	}
+	names = append(names, "varchar(n)", "decimal(p,s)")
+	sort.Strings(names)
+	return names
+}
+
+// ambiguousFieldType reports whether other field types share the Go type of
+// the field type, which then cannot be told from the Go type alone.
+func ambiguousFieldType(name string, cfg *config.Config) bool {
+	t, err := lookupFieldType(strings.TrimSuffix(name, "[]"), cfg)
+	if err != nil {
+		return false
+	}
+	shared := 0
+	for _, other := range fieldTypes {
+		if other.GoType == t.GoType {
+			shared++
+		}
+	}
+	for _, other := range cfg.Types {
+		if other.Go == t.GoType {
+			shared++
+		}
+	}
+	return shared > 1
+}
+
+// fieldTypeImports returns the sorted packages the Go types of the fields
+// need.
+func fieldTypeImports(fields []types.Field, cfg *config.Config) []string {
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		if field.IsEnum {
+			continue
+		}
+		if t, err := lookupFieldType(field.Type, cfg); err == nil {
+			for _, path := range t.Imports {
+				seen[path] = true
+			}
+		}
+	}
+	imports := make([]string, 0, len(seen))
+	for path := range seen {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// syncTypeImports adds the imports of field type packages a model file
+// uses and removes the ones it no longer uses. Other imports are left
+// alone.
+func syncTypeImports(node *ast.File, cfg *config.Config) {
+	used := make(map[string]bool)
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if ident, ok := n.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		case *ast.Ident:
+			// types set by the generators are written as a single ident
+			for _, match := range qualifiedPattern.FindAllStringSubmatch(n.Name, -1) {
+				used[match[1]] = true
+			}
+		}
+		return true
+	})
+
+	managed := map[string]bool{pqImport: true, pgtypeImport: true}
+	for _, t := range fieldTypes {
+		for _, path := range t.Imports {
+			managed[path] = true
+		}
+	}
+	for _, t := range cfg.Types {
+		if t.Import != "" {
+			managed[t.Import] = true
+		}
+	}
+
+	kept := make([]*ast.ImportSpec, 0, len(node.Imports))
+	imported := make(map[string]bool)
+	changed := false
+	for _, spec := range node.Imports {
+		path := importPath(spec)
+		if managed[path] && !used[importName(spec)] {
+			changed = true
+			continue
+		}
+		imported[path] = true
+		kept = append(kept, spec)
+	}
+	for path := range managed {
+		if !imported[path] && used[packageName(path)] {
+			kept = append(kept, &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path)}})
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+
+	// the imports are rewritten as one sorted block, as new models get them
+	sort.Slice(kept, func(i, j int) bool {
+		return importPath(kept[i]) < importPath(kept[j])
+	})
+	specs := make([]ast.Spec, 0, len(kept))
+	for _, spec := range kept {
+		fresh := &ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: spec.Path.Value}}
+		if spec.Name != nil {
+			fresh.Name = ast.NewIdent(spec.Name.Name)
+		}
+		specs = append(specs, fresh)
+	}
+
+	decls := make([]ast.Decl, 0, len(node.Decls)+1)
+	if len(specs) > 0 {
+		decls = append(decls, &ast.GenDecl{Tok: token.IMPORT, Lparen: node.Name.End(), Specs: specs, Rparen: node.Name.End()})
+	}
+	for _, decl := range node.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			continue
+		}
+		decls = append(decls, decl)
+	}
+	node.Decls = decls
+	node.Imports = kept
+}
+
+func importPath(spec *ast.ImportSpec) string {
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return spec.Path.Value
+	}
+	return path
+}
+
+// importName is the name the file refers to the imported package by.
+func importName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return packageName(importPath(spec))
+}
+
+func packageName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// typeSnippet executes a Check or Setup template of a field type for the
+// column. The templates are checked when the config is loaded.
+func typeSnippet(snippet, table, column string) string {
+	rendered, err := config.RenderSnippet(snippet, config.SnippetData{Table: table, Column: column})
+	if err != nil {
+		return snippet
+	}
+	return rendered
+}
@@ -0,0 +1,231 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codegenex/internal/parser"
+	"codegenex/internal/types"
+)
+
+const projectTypes = `{"types": {
+	"money": {"sql": {"postgres": "NUMERIC(19,4)"}, "go": "decimal.Decimal", "import": "github.com/shopspring/decimal", "default": "0", "check": "{{.Column}} >= 0"},
+	"email": {"sql": {"postgres": "CITEXT"}, "go": "string", "check": "{{.Column}} ~ '^[^@]+@[^@]+$'", "setup": "CREATE EXTENSION IF NOT EXISTS citext;"},
+	"string": {"sql": {"postgres": "TEXT"}, "go": "string"}
+}}`
+
+// lastMigration returns the Up and Down sections of the newest migration.
+func lastMigration(t *testing.T, m *Manager) (string, string) {
+	t.Helper()
+	migrations, err := listMigrations(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, migrations[len(migrations)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	up, down, err := splitMigration(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return up, down
+}
+
+func TestLookupFieldType(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantSQL string
+		wantGo  string
+		wantErr string
+	}{
+		{name: "smallint", wantSQL: "SMALLINT", wantGo: "int16"},
+		{name: "json", wantSQL: "JSON", wantGo: "json.RawMessage"},
+		{name: "varchar(20)", wantSQL: "VARCHAR(20)", wantGo: "string"},
+		{name: "decimal", wantSQL: "NUMERIC", wantGo: "string"},
+		{name: "decimal(10,2)", wantSQL: "NUMERIC(10,2)", wantGo: "string"},
+		{name: "time[]", wantSQL: "TIMESTAMP[]", wantGo: "Array[time.Time]"},
+		{name: "varchar", wantErr: `invalid type "varchar", expected varchar(n)`},
+		{name: "decimal(2,4)", wantErr: `invalid type "decimal(2,4)", expected decimal(precision,scale)`},
+		{name: "text(10)", wantErr: "type text takes no modifiers"},
+		{name: "varchar(x)", wantErr: `invalid modifier "x" of type "varchar(x)"`},
+		{name: "", wantErr: "missing type"},
+		{name: "currency", wantErr: `unknown type "currency", expected one of`},
+	}
+
+	cfg := newTestManager(t, `{}`).Config
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lookupFieldType(tt.name, cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Errorf("lookupFieldType() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.SQLType != tt.wantSQL || got.GoType != tt.wantGo {
+				t.Errorf("lookupFieldType() = %s %s, want %s %s", got.SQLType, got.GoType, tt.wantSQL, tt.wantGo)
+			}
+		})
+	}
+}
+
+func TestGenerateEntityRejectsUnknownType(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	fields, err := parser.ParseFields([]string{"title:string", "balance:money"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.GenerateEntity("account", types.CreateAction, fields, types.EntityOptions{})
+	if err == nil || !strings.HasPrefix(err.Error(), `field balance: unknown type "money"`) {
+		t.Fatalf("GenerateEntity() error = %v, want the unknown type reported", err)
+	}
+	if m.Files.Exists(getModelFilePath("Account", m.Config)) {
+		t.Errorf("model written for an entity with an unknown field type")
+	}
+}
+
+func TestExtendedFieldTypes(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "account", types.CreateAction, types.EntityOptions{},
+		"rank:smallint", "payload:json", "code:varchar(20)", "balance:decimal(10,2)", "tags:text[]", "seen:timestamptz:null")
+
+	up, _ := lastMigration(t, m)
+	for _, want := range []string{"rank SMALLINT NOT NULL", "payload JSON NOT NULL", "code VARCHAR(20) NOT NULL", "balance NUMERIC(10,2) NOT NULL", "tags TEXT[] NOT NULL", "seen TIMESTAMPTZ NULL"} {
+		if !strings.Contains(up, want) {
+			t.Errorf("up section lacks %q:\n%s", want, up)
+		}
+	}
+	model, err := m.Files.ReadFile(getModelFilePath("Account", m.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\"encoding/json\"", "int16", "json.RawMessage", "Array[string]", "\"time\""} {
+		if !strings.Contains(string(model), want) {
+			t.Errorf("model lacks %q:\n%s", want, model)
+		}
+	}
+
+	findings, err := m.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finding := range findings {
+		t.Error(finding)
+	}
+}
+
+func TestProjectTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		action    types.Action
+		fields    []string
+		wantUp    []string
+		wantNotUp []string
+		wantModel []string
+	}{
+		{
+			name:   "create",
+			action: types.CreateAction,
+			fields: []string{"balance:money", "fee:money:default=1", "email:email:unique", "name:string"},
+			wantUp: []string{
+				"CREATE EXTENSION IF NOT EXISTS citext;",
+				"balance NUMERIC(19,4) NOT NULL DEFAULT 0 CHECK (balance >= 0)",
+				"fee NUMERIC(19,4) NOT NULL DEFAULT 1 CHECK (fee >= 0)",
+				"email CITEXT NOT NULL UNIQUE CHECK (email ~ '^[^@]+@[^@]+$')",
+				// a project type replaces the built-in one of the same name
+				"name TEXT NOT NULL",
+			},
+			wantModel: []string{
+				"\"github.com/shopspring/decimal\"",
+				"Balance   decimal.Decimal",
+				"Email     string",
+			},
+		},
+		{
+			name:      "add fields",
+			action:    types.AddFieldsAction,
+			fields:    []string{"credit:money"},
+			wantUp:    []string{"ADD COLUMN IF NOT EXISTS credit NUMERIC(19,4) NOT NULL DEFAULT 0 CHECK (credit >= 0);"},
+			wantNotUp: []string{"citext"},
+			wantModel: []string{"\"github.com/shopspring/decimal\"", "Credit    decimal.Decimal"},
+		},
+		{
+			name:      "add fields with setup",
+			action:    types.AddFieldsAction,
+			fields:    []string{"backup_email:email:null"},
+			wantUp:    []string{"CREATE EXTENSION IF NOT EXISTS citext;\n", "ADD COLUMN IF NOT EXISTS backup_email CITEXT NULL CHECK (backup_email ~ '^[^@]+@[^@]+$');"},
+			wantModel: []string{"BackupEmail string"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, projectTypes)
+			if tt.action != types.CreateAction {
+				generate(t, m, "account", types.CreateAction, types.EntityOptions{}, "title:string")
+			}
+			generate(t, m, "account", tt.action, types.EntityOptions{}, tt.fields...)
+
+			up, _ := lastMigration(t, m)
+			for _, want := range tt.wantUp {
+				if !strings.Contains(up, want) {
+					t.Errorf("up section lacks %q:\n%s", want, up)
+				}
+			}
+			for _, unwanted := range tt.wantNotUp {
+				if strings.Contains(up, unwanted) {
+					t.Errorf("up section contains %q:\n%s", unwanted, up)
+				}
+			}
+			model, err := m.Files.ReadFile(getModelFilePath("Account", m.Config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantModel {
+				if !strings.Contains(string(model), want) {
+					t.Errorf("model lacks %q:\n%s", want, model)
+				}
+			}
+
+			findings, err := m.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, finding := range findings {
+				t.Error(finding)
+			}
+		})
+	}
+}
+
+func TestRemoveProjectTypeFieldDropsImport(t *testing.T) {
+	m := newTestManager(t, projectTypes)
+	generate(t, m, "account", types.CreateAction, types.EntityOptions{}, "title:string", "balance:money")
+	generate(t, m, "account", types.RemoveFieldsAction, types.EntityOptions{}, "balance")
+
+	_, down := lastMigration(t, m)
+	want := "ADD COLUMN IF NOT EXISTS balance NUMERIC(19,4) NOT NULL DEFAULT 0 CHECK (balance >= 0);"
+	if !strings.Contains(down, want) {
+		t.Errorf("down section lacks %q:\n%s", want, down)
+	}
+	model, err := m.Files.ReadFile(getModelFilePath("Account", m.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(model), "decimal") {
+		t.Errorf("model still imports decimal:\n%s", model)
+	}
+}
+
+func TestLookupFieldTypeUnknown(t *testing.T) {
+	m := newTestManager(t, projectTypes)
+	_, err := lookupFieldType("currency", m.Config)
+	if err == nil || !strings.Contains(err.Error(), "email") || !strings.Contains(err.Error(), "money") {
+		t.Errorf("lookupFieldType() error = %v, want it to list the project types", err)
+	}
+}
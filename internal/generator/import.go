@@ -0,0 +1,320 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/ddl"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+// ImportSchema reads a pg_dump --schema-only file and generates a model for
+// every table it defines. Existing model files are left untouched.
+func ImportSchema(schemaFile string, cfg *config.Config, fsys files.FS) error {
+	content, err := fsys.ReadFile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("error reading schema file %s: %w", schemaFile, err)
+	}
+
+	statements, err := ddl.Parse(string(content))
+	if err != nil {
+		return fmt.Errorf("error parsing schema file %s: %w", schemaFile, err)
+	}
+
+	schema := ddl.NewSchema()
+	err = schema.ApplyAll(statements)
+	if err != nil {
+		return fmt.Errorf("error reading schema file %s: %w", schemaFile, err)
+	}
+
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range sortTablesByDependency(schema.Tables) {
+		modelName := inflection.Singular(strcase.ToCamel(table.Name))
+		fields := tableFields(schema, table)
+		opts := types.EntityOptions{
+			PrimaryKey: primaryKeyFromTable(table),
+			SoftDelete: table.Column("deleted_at") != nil,
+		}
+		s.SetEntity(importedEntity(modelName, table.Name, fields, opts, s.Entity(modelName)))
+
+		filePath := getModelFilePath(modelName, cfg)
+		if fsys.Exists(filePath) {
+			fmt.Printf("Model file exists, skipping: %s\n", filePath)
+			continue
+		}
+
+		modelData := prepareModelData(modelName, fields, opts, cfg)
+		modelData.Fields = dropImplicitFields(modelData.Fields, table)
+		if !usesTimePackage(modelData.Fields) {
+			modelData.Imports = removeString(modelData.Imports, "time")
+		}
+
+		err = renderModel(modelData, cfg, fsys)
+		if err != nil {
+			return fmt.Errorf("error generating model for table %s: %w", table.Name, err)
+		}
+	}
+
+	return s.Save(fsys, cfg.StateFile)
+}
+
+// importedEntity builds the state entry of an imported table, keeping the
+// relations already recorded for it.
+func importedEntity(modelName, tableName string, fields []types.Field, opts types.EntityOptions, existing *state.Entity) *state.Entity {
+	entity := &state.Entity{
+		Name:       modelName,
+		Table:      tableName,
+		PrimaryKey: opts.PrimaryKey,
+		SoftDelete: opts.SoftDelete,
+		Fields:     fields,
+	}
+	if existing != nil {
+		entity.HasMany = existing.HasMany
+	}
+	for _, field := range fields {
+		if field.IsReference {
+			entity.HasMany = appendUnique(entity.HasMany, referencedModelName(field))
+		}
+	}
+	return entity
+}
+
+// primaryKeyFromTable picks the primary key strategy matching the type of
+// the id column. Integer keys fed by a sequence are taken for serial ones.
+func primaryKeyFromTable(table *ddl.Table) string {
+	column := table.Column("id")
+	if column == nil {
+		return "serial"
+	}
+	switch column.Type {
+	case "bigint", "bigserial":
+		return "bigserial"
+	case "uuid":
+		return "uuid"
+	case "char(26)":
+		return "ulid"
+	default:
+		return "serial"
+	}
+}
+
+// tableFields converts table columns into codegenex fields. The id and
+// timestamp columns are left out as they are added to every model, and so is
+// deleted_at, which marks a soft delete entity.
+func tableFields(schema *ddl.Schema, table *ddl.Table) []types.Field {
+	indexed := make(map[string]bool)
+	for _, index := range schema.TableIndexes(table.Name) {
+		if len(index.Columns) != 1 {
+			continue
+		}
+		// unique indexes of soft delete tables only cover rows not deleted
+		if index.Where != "" && !(index.Unique && isNotDeletedCondition(index.Where)) {
+			continue
+		}
+		if index.Unique {
+			indexed[index.Columns[0]+":unique"] = true
+		} else {
+			indexed[index.Columns[0]] = true
+		}
+	}
+
+	fields := make([]types.Field, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		switch column.Name {
+		case "id", "created_at", "updated_at", "deleted_at":
+			continue
+		}
+
+		field := types.Field{
+			Name:       column.Name,
+			Type:       fieldTypeFromSQL(column.Type),
+			IsNullable: !column.NotNull,
+			IsIndex:    indexed[column.Name],
+			IsUnique:   table.IsUnique(column.Name) || indexed[column.Name+":unique"],
+		}
+		field.UniqueIndex = field.IsUnique && !table.IsUnique(column.Name)
+
+		if enum := schema.Enum(strings.TrimSuffix(column.Type, "[]")); enum != nil {
+			field.IsEnum = true
+			field.EnumValues = enum.Values
+			field.EnumType = enum.Name
+			field.Type = "string"
+		} else if field.Type == "" {
+			fmt.Printf("Warning: unsupported type %s of column %s.%s, using string\n", column.Type, table.Name, column.Name)
+			field.Type = "string"
+		}
+
+		if !strings.HasPrefix(column.Default, "nextval(") {
+			field.DefaultValue = column.Default
+		}
+
+		if fk := table.ForeignKey(column.Name); fk != nil {
+			field.IsReference = true
+			field.RefOptions = refOptionFromOnDelete(fk.OnDelete)
+			field.ReferencedModel = inflection.Singular(strcase.ToCamel(fk.RefTable))
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// isNotDeletedCondition reports whether an index predicate is the soft
+// delete condition, as written by codegenex or printed by pg_dump.
+func isNotDeletedCondition(where string) bool {
+	where = strings.TrimSpace(where)
+	for strings.HasPrefix(where, "(") && strings.HasSuffix(where, ")") {
+		where = strings.TrimSpace(where[1 : len(where)-1])
+	}
+	return strings.EqualFold(where, notDeletedCondition)
+}
+
+// fieldTypeFromSQL maps a normalized SQL column type onto a codegenex field
+// type, returning an empty string for types without a counterpart.
+func fieldTypeFromSQL(sqlType string) string {
+	if strings.HasSuffix(sqlType, "[]") {
+		base := fieldTypeFromSQL(strings.TrimSuffix(sqlType, "[]"))
+		if base == "" {
+			return ""
+		}
+		return base + "[]"
+	}
+
+	base := sqlType
+	if open := strings.Index(base, "("); open >= 0 {
+		base = base[:open]
+	}
+
+	switch base {
+	case "integer", "serial":
+		return "int"
+	case "smallint", "smallserial":
+		return "smallint"
+	case "bigint", "bigserial":
+		return "bigint"
+	case "varchar":
+		// varchar without a length is unbounded like text
+		switch sqlType {
+		case "varchar(255)":
+			return "string"
+		case "varchar":
+			return "text"
+		}
+		return sqlType
+	case "char":
+		return "string"
+	case "timestamp":
+		return "time"
+	case "numeric":
+		if base != sqlType {
+			return "decimal" + strings.TrimPrefix(sqlType, base)
+		}
+		return "float"
+	case "real", "double precision":
+		return "float"
+	case "boolean":
+		return "bool"
+	case "uuid", "text", "citext", "timestamptz", "date", "interval", "bytea", "inet", "json", "jsonb":
+		return base
+	}
+	return ""
+}
+
+func refOptionFromOnDelete(onDelete string) string {
+	switch onDelete {
+	case "CASCADE":
+		return "cascade"
+	case "SET NULL":
+		return "nullify"
+	case "RESTRICT":
+		return "restrict"
+	default:
+		return "no_action"
+	}
+}
+
+// dropImplicitFields removes the ID and timestamp fields prepareModelData
+// adds when the legacy table has no such columns.
+func dropImplicitFields(fields []ModelField, table *ddl.Table) []ModelField {
+	implicit := map[string]string{
+		"ID":        "id",
+		"CreatedAt": "created_at",
+		"UpdatedAt": "updated_at",
+		"DeletedAt": "deleted_at",
+	}
+
+	result := make([]ModelField, 0, len(fields))
+	for _, field := range fields {
+		if column, ok := implicit[field.Name]; ok && table.Column(column) == nil {
+			continue
+		}
+		result = append(result, field)
+	}
+	return result
+}
+
+func usesTimePackage(fields []ModelField) bool {
+	for _, field := range fields {
+		if strings.Contains(field.Type, "time.") {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(values []string, value string) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// sortTablesByDependency orders tables so that referenced tables come before
+// the tables referencing them. Tables in a cycle keep their original order.
+func sortTablesByDependency(tables []*ddl.Table) []*ddl.Table {
+	byName := make(map[string]*ddl.Table, len(tables))
+	for _, table := range tables {
+		byName[table.Name] = table
+	}
+
+	sorted := make([]*ddl.Table, 0, len(tables))
+	state := make(map[string]int)
+
+	var visit func(table *ddl.Table)
+	visit = func(table *ddl.Table) {
+		if state[table.Name] != 0 {
+			return
+		}
+		state[table.Name] = 1
+		for _, column := range table.Columns {
+			fk := table.ForeignKey(column.Name)
+			if fk == nil || fk.RefTable == table.Name {
+				continue
+			}
+			if ref, ok := byName[fk.RefTable]; ok {
+				visit(ref)
+			}
+		}
+		state[table.Name] = 2
+		sorted = append(sorted, table)
+	}
+
+	for _, table := range tables {
+		visit(table)
+	}
+	return sorted
+}
@@ -0,0 +1,451 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+type modelInfo struct {
+	Name    string
+	Columns []modelColumn
+}
+
+type modelColumn struct {
+	Name       string
+	FieldName  string
+	GoType     string
+	IsEnum     bool
+	EnumValues []string
+	// IsPointer is set for pointer fields, which hold nullable columns.
+	IsPointer bool
+}
+
+func (mi *modelInfo) column(name string) (modelColumn, bool) {
+	for _, column := range mi.Columns {
+		if column.Name == name {
+			return column, true
+		}
+	}
+	return modelColumn{}, false
+}
+
+// inspectModel reads the model file of modelName and reconstructs its columns
+// together with the values of the enum types declared in the same file.
+func inspectModel(modelName string, cfg *config.Config, fsys files.FS) (*modelInfo, error) {
+	filePath := getModelFilePath(modelName, cfg)
+
+	fset := token.NewFileSet()
+	node, err := parseGoFile(fset, filePath, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := parseModelDir(cfg, fsys)
+	if err != nil {
+		return nil, err
+	}
+	models := knownModels(nodes)
+
+	var structDecl *ast.TypeSpec
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == modelName {
+			structDecl = ts
+			return false
+		}
+		return true
+	})
+
+	if structDecl == nil {
+		return nil, fmt.Errorf("struct %s not found in file %s", modelName, filePath)
+	}
+
+	structType, ok := structDecl.Type.(*ast.StructType)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a struct type", modelName)
+	}
+
+	enums := collectEnumValues(node)
+
+	info := &modelInfo{Name: modelName}
+	for _, field := range structType.Fields.List {
+		if len(field.Names) == 0 || isRelationExpr(field.Type, models) {
+			continue
+		}
+
+		_, pointer := field.Type.(*ast.StarExpr)
+		goType := strings.TrimPrefix(exprString(field.Type), "*")
+		for _, name := range field.Names {
+			column := modelColumn{
+				Name:      columnName(name.Name, field.Tag),
+				FieldName: name.Name,
+				GoType:    goType,
+				IsPointer: pointer,
+			}
+			if values, ok := enums[goType]; ok {
+				column.IsEnum = true
+				column.EnumValues = values
+			}
+			info.Columns = append(info.Columns, column)
+		}
+	}
+
+	return info, nil
+}
+
+// collectEnumValues maps every enum type declared in the file to the string
+// values of its constants, in declaration order.
+func collectEnumValues(node *ast.File) map[string][]string {
+	enums := make(map[string][]string)
+	for _, decl := range node.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok || valueSpec.Type == nil {
+				continue
+			}
+			typeIdent, ok := valueSpec.Type.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			for _, value := range valueSpec.Values {
+				lit, ok := value.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				unquoted, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					continue
+				}
+				enums[typeIdent.Name] = append(enums[typeIdent.Name], unquoted)
+			}
+		}
+	}
+	return enums
+}
+
+// isRelationExpr reports whether the field type is a relation to another
+// model (*Model or []*Model) rather than a column. Pointers to other types
+// such as *string, *time.Time or a nullable enum are nullable columns.
+func isRelationExpr(expr ast.Expr, models map[string]bool) bool {
+	return relationModel(expr, models) != ""
+}
+
+// relationModel returns the model name of a *Model or []*Model field type
+// whose model is one of models.
+func relationModel(expr ast.Expr, models map[string]bool) string {
+	name := pointerTypeName(expr)
+	if !models[name] {
+		return ""
+	}
+	return name
+}
+
+// pointerTypeName returns the type name of a *Type or []*Type field type of
+// the same package.
+func pointerTypeName(expr ast.Expr) string {
+	if array, ok := expr.(*ast.ArrayType); ok && array.Len == nil {
+		expr = array.Elt
+	}
+	star, ok := expr.(*ast.StarExpr)
+	if !ok {
+		return ""
+	}
+	ident, ok := star.X.(*ast.Ident)
+	if !ok || !ident.IsExported() {
+		return ""
+	}
+	return ident.Name
+}
+
+// columnName returns the column of a struct field, honouring a db tag added
+// by hand before falling back to the snake case field name.
+func columnName(fieldName string, tag *ast.BasicLit) string {
+	if tag != nil {
+		if unquoted, err := strconv.Unquote(tag.Value); err == nil {
+			if name := strings.Split(reflect.StructTag(unquoted).Get("db"), ",")[0]; name != "" && name != "-" {
+				return name
+			}
+		}
+	}
+	return strcase.ToSnake(fieldName)
+}
+
+func exprString(expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, token.NewFileSet(), expr); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// InspectModelDir parses every Go file in the model directory and
+// reconstructs the entities codegenex generated there. Structs with a
+// TableName method are treated as models; indexes, unique constraints and
+// defaults are not visible in Go code and are not recovered. Field types are
+// derived from the Go types and are approximate: a string field may as well
+// be uuid, text or varchar(n), an int64 one bigint.
+func InspectModelDir(cfg *config.Config, fsys files.FS) ([]*state.Entity, error) {
+	nodes, err := parseModelDir(cfg, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	enums := make(map[string][]string)
+	tableNames := make(map[string]string)
+	for _, node := range nodes {
+		for typeName, values := range collectEnumValues(node) {
+			enums[typeName] = values
+		}
+		for typeName, table := range collectTableNames(node) {
+			tableNames[typeName] = table
+		}
+	}
+	structs := collectStructs(nodes)
+	models := knownModels(nodes)
+
+	entities := make([]*state.Entity, 0, len(models))
+	for _, ts := range structs {
+		if !models[ts.Name.Name] {
+			continue
+		}
+		entity := inspectStruct(ts, enums, models, cfg)
+		if table := tableNames[ts.Name.Name]; table != "" {
+			entity.Table = table
+		}
+		entities = append(entities, entity)
+	}
+
+	return entities, nil
+}
+
+// parseModelDir parses the Go files of the model directory.
+func parseModelDir(cfg *config.Config, fsys files.FS) ([]*ast.File, error) {
+	names, err := fsys.ReadDir(cfg.ModelDir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading model directory %s: %w", cfg.ModelDir, err)
+	}
+
+	fset := token.NewFileSet()
+	nodes := make([]*ast.File, 0, len(names))
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		node, err := parseGoFile(fset, filepath.Join(cfg.ModelDir, name), fsys)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func collectStructs(nodes []*ast.File) []*ast.TypeSpec {
+	structs := make([]*ast.TypeSpec, 0)
+	for _, node := range nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if ts, ok := n.(*ast.TypeSpec); ok {
+				if _, ok := ts.Type.(*ast.StructType); ok {
+					structs = append(structs, ts)
+				}
+			}
+			return true
+		})
+	}
+	return structs
+}
+
+// knownModels returns the names of the models among the files, the structs
+// with a TableName method.
+func knownModels(nodes []*ast.File) map[string]bool {
+	tableNames := make(map[string]string)
+	for _, node := range nodes {
+		for typeName, table := range collectTableNames(node) {
+			tableNames[typeName] = table
+		}
+	}
+
+	models := make(map[string]bool)
+	for _, ts := range collectStructs(nodes) {
+		if _, ok := tableNames[ts.Name.Name]; ok {
+			models[ts.Name.Name] = true
+		}
+	}
+	return models
+}
+
+func inspectStruct(ts *ast.TypeSpec, enums map[string][]string, models map[string]bool, cfg *config.Config) *state.Entity {
+	modelName := ts.Name.Name
+	entity := &state.Entity{
+		Name:   modelName,
+		Table:  inflection.Plural(strcase.ToSnake(modelName)),
+		Fields: make([]types.Field, 0),
+	}
+
+	for _, structField := range ts.Type.(*ast.StructType).Fields.List {
+		if related := relationModel(structField.Type, models); related != "" {
+			if _, isSlice := structField.Type.(*ast.ArrayType); isSlice {
+				entity.HasMany = append(entity.HasMany, related)
+			}
+			continue
+		}
+
+		fieldType := structField.Type
+		nullable := false
+		if star, ok := fieldType.(*ast.StarExpr); ok {
+			fieldType = star.X
+			nullable = true
+		}
+		goType := exprString(fieldType)
+
+		for _, name := range structField.Names {
+			column := columnName(name.Name, structField.Tag)
+			switch column {
+			case "id":
+				// string ids are taken for uuid keys, integer ones for serial, see
+				// inspectedPrimaryKey
+				if goType == "string" {
+					entity.PrimaryKey = "uuid"
+				}
+				continue
+			case "created_at", "updated_at":
+				continue
+			case "deleted_at":
+				entity.SoftDelete = true
+				continue
+			}
+
+			field := types.Field{
+				Name:       column,
+				Type:       fieldTypeFromGo(goType, cfg),
+				IsNullable: nullable,
+			}
+			if values, ok := enums[goType]; ok {
+				field.IsEnum = true
+				field.EnumValues = values
+				field.Type = "string"
+			}
+
+			if strings.HasSuffix(column, "_id") {
+				referenced := inflection.Singular(strcase.ToCamel(strings.TrimSuffix(column, "_id")))
+				if models[referenced] {
+					field.IsReference = true
+					field.RefOptions = "cascade"
+				}
+			}
+
+			entity.Fields = append(entity.Fields, field)
+		}
+	}
+
+	return entity
+}
+
+// fieldTypeFromGo maps the Go type of a model field back onto a codegenex
+// field type. Go types without a built-in counterpart are looked up in the
+// project types of the config, unknown ones are kept verbatim.
+func fieldTypeFromGo(goType string, cfg *config.Config) string {
+	if strings.HasPrefix(goType, "[]") && goType != "[]byte" {
+		return fieldTypeFromGo(strings.TrimPrefix(goType, "[]"), cfg) + "[]"
+	}
+	for _, array := range []string{"Array[", "pgtype.FlatArray["} {
+		if strings.HasPrefix(goType, array) && strings.HasSuffix(goType, "]") {
+			return fieldTypeFromGo(strings.TrimSuffix(strings.TrimPrefix(goType, array), "]"), cfg) + "[]"
+		}
+	}
+	for element, array := range pqArrayTypes {
+		if goType == array {
+			return fieldTypeFromGo(element, cfg) + "[]"
+		}
+	}
+
+	switch goType {
+	case "int", "int32", "int64":
+		return "int"
+	case "int16":
+		return "smallint"
+	case "[]byte":
+		return "bytea"
+	case "json.RawMessage":
+		return "json"
+	case "string":
+		return "string"
+	case "bool":
+		return "bool"
+	case "time.Time":
+		return "time"
+	case "float32", "float64":
+		return "float"
+	case "map[string]interface{}", "map[string]any":
+		return "jsonb"
+	}
+
+	names := make([]string, 0, 1)
+	for name, t := range cfg.Types {
+		if t.Go == goType {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		// several project types may share a Go type, take the same one
+		// every time
+		sort.Strings(names)
+		return names[0]
+	}
+	return goType
+}
+
+// collectTableNames maps model types to the table returned by their
+// TableName method. An empty value means the method has no literal result.
+func collectTableNames(node *ast.File) map[string]string {
+	tables := make(map[string]string)
+	for _, decl := range node.Decls {
+		funcDecl, ok := decl.(*ast.FuncDecl)
+		if !ok || funcDecl.Name.Name != "TableName" || funcDecl.Recv == nil || len(funcDecl.Recv.List) != 1 {
+			continue
+		}
+
+		recv := funcDecl.Recv.List[0].Type
+		if star, ok := recv.(*ast.StarExpr); ok {
+			recv = star.X
+		}
+		ident, ok := recv.(*ast.Ident)
+		if !ok {
+			continue
+		}
+
+		tables[ident.Name] = ""
+		if funcDecl.Body == nil || len(funcDecl.Body.List) != 1 {
+			continue
+		}
+		ret, ok := funcDecl.Body.List[0].(*ast.ReturnStmt)
+		if !ok || len(ret.Results) != 1 {
+			continue
+		}
+		if lit, ok := ret.Results[0].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+			if table, err := strconv.Unquote(lit.Value); err == nil {
+				tables[ident.Name] = table
+			}
+		}
+	}
+	return tables
+}
@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"go/parser"
+	"testing"
+)
+
+func TestRelationModel(t *testing.T) {
+	models := map[string]bool{"User": true, "Post": true}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "*User", want: "User"},
+		{expr: "[]*Post", want: "Post"},
+		{expr: "*OrderStatus", want: ""},
+		{expr: "[]*OrderStatus", want: ""},
+		{expr: "*string", want: ""},
+		{expr: "*time.Time", want: ""},
+		{expr: "User", want: ""},
+		{expr: "[2]*User", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parser.ParseExpr(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := relationModel(expr, models)
+			if got != tt.want {
+				t.Errorf("relationModel(%s) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInspectModelDirNullableEnum(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	model := `package models
+
+type Order struct {
+	ID     int64
+	Status *OrderStatus
+	User   *User
+}
+
+type OrderStatus string
+
+const (
+	OrderStatusNew  OrderStatus = "new"
+	OrderStatusPaid OrderStatus = "paid"
+)
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+type User struct {
+	ID     int64
+	Orders []*Order
+}
+
+func (User) TableName() string {
+	return "users"
+}
+`
+	err := m.Files.WriteFile(getModelFilePath("Order", m.Config), []byte(model), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entities, err := InspectModelDir(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 2 || entities[0].Name != "Order" {
+		t.Fatalf("entities = %v, want Order and User", entities)
+	}
+	order := entities[0]
+	if len(order.Fields) != 1 {
+		t.Fatalf("fields of Order = %v, want status only", order.Fields)
+	}
+	status := order.Fields[0]
+	if status.Name != "status" || !status.IsEnum || !status.IsNullable || len(status.EnumValues) != 2 {
+		t.Errorf("status = %+v, want a nullable enum with 2 values", status)
+	}
+	if len(entities[1].HasMany) != 1 || entities[1].HasMany[0] != "Order" {
+		t.Errorf("has many of User = %v, want [Order]", entities[1].HasMany)
+	}
+
+	info, err := inspectModel("Order", m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := info.column("status"); !ok {
+		t.Errorf("columns of Order = %v, want status among them", info.Columns)
+	}
+	if _, ok := info.column("user"); ok {
+		t.Errorf("columns of Order = %v, want the relation left out", info.Columns)
+	}
+}
@@ -0,0 +1,141 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/lint"
+)
+
+// LintMigrations checks every migration in the migration directory, in
+// version order, against the columns of the current models.
+func LintMigrations(cfg *config.Config, fsys files.FS) ([]lint.Finding, error) {
+	linter, err := lint.New(cfg.Lint)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := readMigrations(cfg, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	linter.ModelColumns, err = modelColumns(cfg, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return linter.Check(migrations), nil
+}
+
+// VerifyMigrations checks that the Down section of every migration undoes
+// its Up section.
+func VerifyMigrations(cfg *config.Config, fsys files.FS) ([]lint.Finding, error) {
+	migrations, err := readMigrations(cfg, fsys)
+	if err != nil {
+		return nil, err
+	}
+	return lint.Verify(migrations), nil
+}
+
+// readMigrations reads the migrations of the migration directory in
+// version order.
+func readMigrations(cfg *config.Config, fsys files.FS) ([]lint.Migration, error) {
+	names, err := listMigrations(cfg, fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]lint.Migration, 0, len(names))
+	for _, name := range names {
+		filePath := filepath.Join(getMigrationDir(cfg), name)
+		content, err := fsys.ReadFile(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %w", filePath, err)
+		}
+		migrations = append(migrations, lint.Migration{File: filePath, Content: string(content)})
+	}
+	return migrations, nil
+}
+
+// LintNewMigrations lints after generation and prints the findings of the
+// migrations that are not in before, the migrations listed beforehand. Error
+// findings fail the run, so nothing it generated is written.
+func LintNewMigrations(before []string, cfg *config.Config, fsys files.FS) error {
+	findings, err := LintMigrations(cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	existed := make(map[string]bool, len(before))
+	for _, name := range before {
+		existed[name] = true
+	}
+	errorCount := 0
+	for _, finding := range findings {
+		if existed[filepath.Base(finding.File)] {
+			continue
+		}
+		fmt.Println(finding)
+		if finding.Severity == lint.Error {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("%d lint error(s) in the generated migrations, change the fields or lower the rule severity in the lint config", errorCount)
+	}
+	return nil
+}
+
+// listMigrations returns the migration file names ordered by version.
+func listMigrations(cfg *config.Config, fsys files.FS) ([]string, error) {
+	names, err := fsys.ReadDir(getMigrationDir(cfg))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading migration directory: %w", err)
+	}
+
+	migrations := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := migrationVersion(name); ok {
+			migrations = append(migrations, name)
+		}
+	}
+	sort.SliceStable(migrations, func(i, j int) bool {
+		vi, _ := migrationVersion(migrations[i])
+		vj, _ := migrationVersion(migrations[j])
+		return vi < vj
+	})
+	return migrations, nil
+}
+
+// modelColumns maps the table of every model to its columns.
+func modelColumns(cfg *config.Config, fsys files.FS) (map[string][]string, error) {
+	entities, err := InspectModelDir(cfg, fsys)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string][]string, len(entities))
+	for _, entity := range entities {
+		names := []string{"id", "created_at", "updated_at"}
+		if entity.SoftDelete {
+			names = append(names, "deleted_at")
+		}
+		for _, field := range entity.Fields {
+			names = append(names, field.Name)
+		}
+		columns[entity.Table] = names
+	}
+	return columns, nil
+}
@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codegenex/internal/parser"
+	"codegenex/internal/types"
+)
+
+func TestGenerateEntityFailsOnLintErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		field   string
+		wantErr bool
+	}{
+		{name: "not null without default", config: `{}`, field: "code:varchar(10)", wantErr: true},
+		{name: "nullable", config: `{}`, field: "code:varchar(10):null"},
+		{name: "default", config: `{}`, field: "code:varchar(10):default='x'"},
+		{name: "backfill", config: `{}`, field: "code:varchar(10):backfill='x'"},
+		{name: "rule lowered to warning", config: `{"lint": {"not-null-without-default": "warning"}}`, field: "code:varchar(10)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, tt.config)
+			generate(t, m, "account", types.CreateAction, types.EntityOptions{}, "name:string")
+
+			fields, err := parser.ParseFields([]string{tt.field})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = m.GenerateEntity("account", types.AddFieldsAction, fields, types.EntityOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GenerateEntity() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGenerateSeedFailsOnLintErrors(t *testing.T) {
+	m := newTestManager(t, `{"template_dir": "templates", "lint": {"missing-down": "error"}}`)
+	generate(t, m, "account", types.CreateAction, types.EntityOptions{}, "name:string")
+
+	// a seed template without a Down section
+	override := filepath.Join(m.Config.TemplateDir, "migrations", "seed.tmpl")
+	err := m.Files.WriteFile(override, []byte("-- +goose Up\nINSERT INTO {{.TableName}} ({{join .Columns \", \"}}) VALUES (1);\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := filepath.Join(t.TempDir(), "accounts.csv")
+	err = m.Files.WriteFile(data, []byte("id\n1\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.GenerateSeed("account", data)
+	if err == nil || !strings.HasPrefix(err.Error(), "1 lint error(s)") {
+		t.Errorf("GenerateSeed() error = %v, want a lint error", err)
+	}
+}
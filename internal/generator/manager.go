@@ -2,40 +2,60 @@ package generator
 
 import (
 	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/lint"
 	"codegenex/internal/types"
 	"fmt"
 )
 
 type Manager struct {
 	Config *config.Config
+	Files  files.FS
 }
 
 func NewManager(cfg *config.Config) *Manager {
-	return &Manager{Config: cfg}
+	return &Manager{Config: cfg, Files: files.OS}
 }
 
-func (m *Manager) GenerateEntity(entityName string, action types.Action, fields []types.Field) error {
-	switch action {
-	case types.CreateAction:
-		return m.handleCreateAction(entityName, fields)
-	case types.AddFieldsAction:
-		return m.handleAddFieldsAction(entityName, fields)
-	case types.RemoveFieldsAction:
-		return m.handleRemoveFieldsAction(entityName, fields)
-	case types.DropAction:
-		return m.handleDropAction(entityName)
-	default:
-		return fmt.Errorf("unknown action: %s", action)
+func (m *Manager) GenerateEntity(entityName string, action types.Action, fields []types.Field, opts types.EntityOptions) error {
+	fields, opts, err := ResolveEntity(entityName, action, fields, opts, m.Config, m.Files)
+	if err != nil {
+		return err
 	}
+
+	return m.withLint(func() error {
+		switch action {
+		case types.CreateAction:
+			return m.handleCreateAction(entityName, fields, opts)
+		case types.AddFieldsAction:
+			return m.handleAddFieldsAction(entityName, fields, opts)
+		case types.RemoveFieldsAction:
+			return m.handleRemoveFieldsAction(entityName, fields, opts)
+		case types.DropAction:
+			return m.handleDropAction(entityName, fields, opts)
+		default:
+			return fmt.Errorf("unknown action: %s", action)
+		}
+	})
 }
 
-func (m *Manager) handleCreateAction(entityName string, fields []types.Field) error {
-	err := m.GenerateAndSaveMigration(entityName, fields, types.CreateAction)
+func (m *Manager) handleCreateAction(entityName string, fields []types.Field, opts types.EntityOptions) error {
+	err := m.GenerateAndSaveMigration(entityName, fields, types.CreateAction, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.GenerateAndSaveModel(entityName, fields, types.CreateAction, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.UpdateState(entityName, fields, types.CreateAction, opts)
 	if err != nil {
 		return err
 	}
 
-	err = m.GenerateAndSaveModel(entityName, fields, types.CreateAction)
+	err = m.GenerateArtifacts(entityName, fields, types.CreateAction, opts)
 	if err != nil {
 		return err
 	}
@@ -43,13 +63,23 @@ func (m *Manager) handleCreateAction(entityName string, fields []types.Field) er
 	return nil
 }
 
-func (m *Manager) handleAddFieldsAction(entityName string, fields []types.Field) error {
-	err := m.GenerateAndSaveMigration(entityName, fields, types.AddFieldsAction)
+func (m *Manager) handleAddFieldsAction(entityName string, fields []types.Field, opts types.EntityOptions) error {
+	err := m.GenerateAndSaveMigration(entityName, fields, types.AddFieldsAction, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.GenerateAndSaveModel(entityName, fields, types.AddFieldsAction, opts)
 	if err != nil {
 		return err
 	}
 
-	err = m.GenerateAndSaveModel(entityName, fields, types.AddFieldsAction)
+	err = m.UpdateState(entityName, fields, types.AddFieldsAction, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.GenerateArtifacts(entityName, fields, types.AddFieldsAction, opts)
 	if err != nil {
 		return err
 	}
@@ -57,13 +87,23 @@ func (m *Manager) handleAddFieldsAction(entityName string, fields []types.Field)
 	return nil
 }
 
-func (m *Manager) handleRemoveFieldsAction(entityName string, fields []types.Field) error {
-	err := m.GenerateAndSaveMigration(entityName, fields, types.RemoveFieldsAction)
+func (m *Manager) handleRemoveFieldsAction(entityName string, fields []types.Field, opts types.EntityOptions) error {
+	err := m.GenerateAndSaveMigration(entityName, fields, types.RemoveFieldsAction, opts)
 	if err != nil {
 		return err
 	}
 
-	err = m.GenerateAndSaveModel(entityName, fields, types.RemoveFieldsAction)
+	err = m.GenerateAndSaveModel(entityName, fields, types.RemoveFieldsAction, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.UpdateState(entityName, fields, types.RemoveFieldsAction, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.GenerateArtifacts(entityName, fields, types.RemoveFieldsAction, opts)
 	if err != nil {
 		return err
 	}
@@ -71,8 +111,8 @@ func (m *Manager) handleRemoveFieldsAction(entityName string, fields []types.Fie
 	return nil
 }
 
-func (m *Manager) handleDropAction(entityName string) error {
-	err := m.GenerateAndSaveMigration(entityName, nil, types.DropAction)
+func (m *Manager) handleDropAction(entityName string, fields []types.Field, opts types.EntityOptions) error {
+	err := m.GenerateAndSaveMigration(entityName, fields, types.DropAction, opts)
 	if err != nil {
 		return err
 	}
@@ -82,18 +122,121 @@ func (m *Manager) handleDropAction(entityName string) error {
 		return err
 	}
 
+	err = m.UpdateState(entityName, nil, types.DropAction, opts)
+	if err != nil {
+		return err
+	}
+
+	err = m.GenerateArtifacts(entityName, nil, types.DropAction, opts)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
-func (m *Manager) GenerateAndSaveMigration(entityName string, fields []types.Field, action types.Action) error {
-	return GenerateAndSaveMigration(entityName, fields, action, m.Config)
+func (m *Manager) GenerateAndSaveMigration(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions) error {
+	return GenerateAndSaveMigration(entityName, fields, action, opts, m.Config, m.Files)
+}
+
+func (m *Manager) GenerateAndSaveModel(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions) error {
+	return GenerateModel(entityName, fields, action, opts, m.Config, m.Files)
+}
+
+func (m *Manager) UpdateState(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions) error {
+	return UpdateState(entityName, fields, action, opts, m.Config, m.Files)
 }
 
-func (m *Manager) GenerateAndSaveModel(entityName string, fields []types.Field, action types.Action) error {
-	return GenerateModel(entityName, fields, action)
+func (m *Manager) BuildStateFromModels() error {
+	return BuildStateFromModels(m.Config, m.Files)
+}
+
+func (m *Manager) BuildStateFromMigrations(until int64) error {
+	return BuildStateFromMigrations(until, m.Config, m.Files)
+}
+
+func (m *Manager) Squash(until int64) error {
+	return m.withLint(func() error {
+		return Squash(until, m.Config, m.Files)
+	})
+}
+
+func (m *Manager) RecordRun(command string, changes []files.Change) error {
+	return RecordRun(command, changes, m.Config, m.Files)
+}
+
+func (m *Manager) Undo(n int) error {
+	return Undo(n, m.Config, m.Files)
+}
+
+func (m *Manager) GenerateArtifacts(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions) error {
+	return GenerateArtifacts(entityName, fields, action, opts, m.Config, m.Files)
+}
+
+func (m *Manager) GenerateBatch(specs []types.EntitySpec) error {
+	return m.withLint(func() error {
+		return GenerateBatch(specs, m.Config, m.Files)
+	})
+}
+
+func (m *Manager) RenameField(entityName, oldName, newName string) error {
+	return m.withLint(func() error {
+		return RenameField(entityName, oldName, newName, m.Config, m.Files)
+	})
+}
+
+func (m *Manager) ChangeFieldType(entityName string, field types.Field) error {
+	return m.withLint(func() error {
+		return ChangeFieldType(entityName, field, m.Config, m.Files)
+	})
+}
+
+func (m *Manager) Contract(entityName string) error {
+	return m.withLint(func() error {
+		return Contract(entityName, m.Config, m.Files)
+	})
+}
+
+// withLint runs generate and lints the migrations it added. Every command
+// that writes migrations goes through it.
+func (m *Manager) withLint(generate func() error) error {
+	before, err := listMigrations(m.Config, m.Files)
+	if err != nil {
+		return err
+	}
+
+	err = generate()
+	if err != nil {
+		return err
+	}
+
+	return LintNewMigrations(before, m.Config, m.Files)
+}
+
+func (m *Manager) LoadBatchSpec(specFile string) ([]types.EntitySpec, error) {
+	return LoadBatchSpec(specFile, m.Files)
+}
+
+func (m *Manager) Lint() ([]lint.Finding, error) {
+	return LintMigrations(m.Config, m.Files)
+}
+
+func (m *Manager) Verify() ([]lint.Finding, error) {
+	return VerifyMigrations(m.Config, m.Files)
+}
+
+func (m *Manager) GenerateSeed(entityName, dataFile string) error {
+	return m.withLint(func() error {
+		return GenerateAndSaveSeed(entityName, dataFile, m.Config, m.Files)
+	})
+}
+
+func (m *Manager) ImportSchema(schemaFile string) error {
+	return m.withLint(func() error {
+		return ImportSchema(schemaFile, m.Config, m.Files)
+	})
 }
 
 func (m *Manager) RemoveModel(entityName string) error {
-	// TODO:
-	return nil
+	return RemoveModel(entityName, m.Config, m.Files)
 }
@@ -2,26 +2,38 @@ package generator
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"os"
+	"io/fs"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
 
 	"codegenex/internal/config"
+	"codegenex/internal/files"
 	"codegenex/internal/types"
 
 	"github.com/iancoleman/strcase"
 	"github.com/jinzhu/inflection"
 )
 
+// notDeletedCondition selects the rows of soft delete tables that are not
+// deleted.
+const notDeletedCondition = "deleted_at IS NULL"
+
 type MigrationData struct {
 	TableName  string
+	PrimaryKey string
+	SoftDelete bool
 	Fields     []FieldData
 	Indexes    []IndexData
 	References []ReferenceData
 	Enums      []EnumData
+	// Setup holds the setup snippets of the field types, run before the
+	// columns are added.
+	Setup []string
 }
 
 type FieldData struct {
@@ -33,15 +45,41 @@ type FieldData struct {
 	IsEnum       bool
 	EnumName     string
 	IsUnique     bool
-	IsReference  bool
-	RefTable     string
-	RefColumn    string
-	OnDelete     string
+	// Check is the CHECK expression of the field type.
+	Check       string
+	IsReference bool
+	RefTable    string
+	RefColumn   string
+	OnDelete    string
+	// Backfill is the expression that fills the column of existing rows.
+	// The column is added as nullable and made NOT NULL afterwards when
+	// BackfillNotNull is set.
+	Backfill        string
+	BackfillNotNull bool
+}
+
+// BackfillData describes the migration that fills new columns of existing
+// rows in batches of primary key values.
+type BackfillData struct {
+	TableName string
+	Fields    []FieldData
+	BatchSize int
+	// IDType is the SQL type of the primary key. Integer keys are walked in
+	// id ranges, other keys in pages ordered by id.
+	IDType    string
+	IntegerID bool
+	// SetNotNull is set when any of the fields becomes NOT NULL.
+	SetNotNull bool
 }
 
 type IndexData struct {
 	Name    string
 	Columns []string
+	Unique  bool
+	Where   string
+	// Method is the index access method, GIN for array columns.
+	Method     string
+	Concurrent bool
 }
 
 type ReferenceData struct {
@@ -56,64 +94,252 @@ type EnumData struct {
 	Values []string
 }
 
-func GenerateAndSaveMigration(entityName string, fields []types.Field, action types.Action, cfg *config.Config) error {
-	migrationSQL, err := GenerateMigration(entityName, fields, action)
+func GenerateAndSaveMigration(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions, cfg *config.Config, fsys files.FS) error {
+	migrationData := prepareMigrationData(entityName, fields, action, opts, cfg)
+
+	// new tables are empty, so only indexes on existing ones are built
+	// concurrently, which cannot run inside the transaction of the migration
+	var concurrent []IndexData
+	if action == types.AddFieldsAction || action == types.RemoveFieldsAction {
+		migrationData.Indexes, concurrent = splitConcurrentIndexes(migrationData.Indexes)
+	}
+
+	migrationSQL, err := renderMigration(action.String(), migrationData, cfg, fsys)
 	if err != nil {
 		return fmt.Errorf("error generating migration: %w", err)
 	}
 
-	fileName := generateMigrationFileName(entityName, action)
-	err = saveMigrationToFile(migrationSQL, fileName, cfg)
+	// indexes are dropped before their columns and built after them
+	if action == types.RemoveFieldsAction && len(concurrent) > 0 {
+		err = saveConcurrentIndexMigration("remove_indexes_from_"+entityName, "drop_indexes_concurrently", migrationData.TableName, concurrent, cfg, fsys)
+		if err != nil {
+			return err
+		}
+	}
+
+	fileName, err := generateMigrationFileName(entityName, action, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	err = saveMigrationToFile(migrationSQL, fileName, cfg, fsys)
 	if err != nil {
 		return fmt.Errorf("error saving migration: %w", err)
 	}
+	fmt.Printf("Migration file generated: %s\n", fileName)
+
+	// the new columns are filled before they are indexed
+	if action == types.AddFieldsAction {
+		err = saveBackfillMigration(entityName, migrationData, opts, cfg, fsys)
+		if err != nil {
+			return err
+		}
+	}
+
+	if action != types.RemoveFieldsAction && len(concurrent) > 0 {
+		err = saveConcurrentIndexMigration("add_indexes_to_"+entityName, "create_indexes_concurrently", migrationData.TableName, concurrent, cfg, fsys)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveConcurrentIndexMigration writes the concurrent index changes into a
+// migration of their own marked -- +goose NO TRANSACTION.
+func saveConcurrentIndexMigration(name, templateName, tableName string, indexes []IndexData, cfg *config.Config, fsys files.FS) error {
+	migrationData := MigrationData{TableName: tableName, Indexes: indexes}
+	migrationSQL, err := renderMigration(templateName, migrationData, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error generating index migration: %w", err)
+	}
+
+	fileName, err := newMigrationFileName(name, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	err = saveMigrationToFile(migrationSQL, fileName, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error saving index migration: %w", err)
+	}
+	fmt.Printf("Migration file generated: %s\n", fileName)
+	return nil
+}
+
+// saveBackfillMigration writes the migration that fills the fields with a
+// backfill expression, if there are any. It runs outside a transaction so
+// that every batch is committed on its own.
+func saveBackfillMigration(entityName string, migrationData MigrationData, opts types.EntityOptions, cfg *config.Config, fsys files.FS) error {
+	data := BackfillData{
+		TableName: migrationData.TableName,
+		Fields:    make([]FieldData, 0),
+		BatchSize: cfg.BackfillBatchSize,
+	}
+	for _, field := range migrationData.Fields {
+		if field.Backfill != "" {
+			data.Fields = append(data.Fields, field)
+			data.SetNotNull = data.SetNotNull || field.BackfillNotNull
+		}
+	}
+	if len(data.Fields) == 0 {
+		return nil
+	}
 
+	strategy := getPrimaryKey(opts.PrimaryKey)
+	data.IDType = getSQLType(types.Field{Type: strategy.RefType}, cfg)
+	data.IntegerID = strategy.GoType == "int64"
+
+	funcMap := template.FuncMap{
+		"toSnake": strcase.ToSnake,
+	}
+	tmpl, err := loadTemplate("migrations/backfill_fields.tmpl", funcMap, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return fmt.Errorf("error executing migration template: %w", err)
+	}
+
+	fileName, err := newMigrationFileName("backfill_"+entityName, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	err = saveMigrationToFile(buf.String(), fileName, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error saving backfill migration: %w", err)
+	}
 	fmt.Printf("Migration file generated: %s\n", fileName)
 	return nil
 }
 
-func GenerateMigration(entityName string, fields []types.Field, action types.Action) (string, error) {
+// splitConcurrentIndexes separates the indexes to build concurrently.
+func splitConcurrentIndexes(indexes []IndexData) ([]IndexData, []IndexData) {
+	regular := make([]IndexData, 0, len(indexes))
+	concurrent := make([]IndexData, 0)
+	for _, index := range indexes {
+		if index.Concurrent {
+			concurrent = append(concurrent, index)
+		} else {
+			regular = append(regular, index)
+		}
+	}
+	return regular, concurrent
+}
+
+func GenerateMigration(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions, cfg *config.Config, fsys files.FS) (string, error) {
+	migrationData := prepareMigrationData(entityName, fields, action, opts, cfg)
+	return renderMigration(action.String(), migrationData, cfg, fsys)
+}
+
+func renderMigration(templateName string, migrationData MigrationData, cfg *config.Config, fsys files.FS) (string, error) {
+	funcMap := template.FuncMap{
+		"toSnake": strcase.ToSnake,
+	}
+
+	tmpl, err := loadTemplate("migrations/"+templateName+".tmpl", funcMap, cfg, fsys)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, migrationData)
+	if err != nil {
+		return "", fmt.Errorf("error executing migration template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+func prepareMigrationData(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions, cfg *config.Config) MigrationData {
 	tableName := inflection.Plural(strcase.ToSnake(entityName))
 
 	migrationData := MigrationData{
 		TableName:  tableName,
+		PrimaryKey: getPrimaryKey(opts.PrimaryKey).SQLType,
+		SoftDelete: opts.SoftDelete,
 		Fields:     make([]FieldData, 0),
 		Indexes:    make([]IndexData, 0),
 		References: make([]ReferenceData, 0),
 		Enums:      make([]EnumData, 0),
+		Setup:      make([]string, 0),
 	}
 
 	for _, field := range fields {
 		fieldData := FieldData{
 			Name:         field.Name,
 			Type:         field.Type,
-			SQLType:      getSQLType(field),
+			SQLType:      getSQLType(field, cfg),
 			IsNullable:   field.IsNullable,
 			DefaultValue: field.DefaultValue,
 			IsEnum:       field.IsEnum,
 			IsUnique:     field.IsUnique,
 		}
 
+		if t, err := lookupFieldType(field.Type, cfg); err == nil && !field.IsEnum {
+			if fieldData.DefaultValue == "" {
+				fieldData.DefaultValue = t.Default
+			}
+			if t.Check != "" {
+				fieldData.Check = typeSnippet(t.Check, tableName, field.Name)
+			}
+			if t.Setup != "" {
+				migrationData.Setup = appendUnique(migrationData.Setup, typeSnippet(t.Setup, tableName, field.Name))
+			}
+		}
+
+		// the column is filled once, by the add_fields run that adds it
+		if field.Backfill != "" && action == types.AddFieldsAction {
+			fieldData.Backfill = field.Backfill
+			fieldData.BackfillNotNull = !field.IsNullable
+			fieldData.IsNullable = true
+		}
+
 		if field.IsEnum {
-			enumName := fmt.Sprintf("%s_%s", tableName, inflection.Plural(field.Name))
+			enumName := enumTypeName(tableName, field)
 			fieldData.EnumName = enumName
-			migrationData.Enums = append(migrationData.Enums, EnumData{
-				Name:   enumName,
-				Values: field.EnumValues,
-			})
+			if !hasEnumWithName(migrationData.Enums, enumName) {
+				migrationData.Enums = append(migrationData.Enums, EnumData{
+					Name:   enumName,
+					Values: field.EnumValues,
+				})
+			}
+		}
+
+		// soft deleted rows must not block reusing a unique value; columns
+		// of phased changes have a unique index already
+		uniqueIndex := field.IsUnique && (opts.SoftDelete || field.UniqueIndex)
+		if uniqueIndex {
+			fieldData.IsUnique = false
+			index := IndexData{
+				Name:       fmt.Sprintf("uniq_%s_%s", tableName, field.Name),
+				Columns:    []string{field.Name},
+				Unique:     true,
+				Concurrent: field.IndexMode == types.IndexConcurrent,
+			}
+			if opts.SoftDelete {
+				index.Where = notDeletedCondition
+			}
+			migrationData.Indexes = append(migrationData.Indexes, index)
 		}
 
 		migrationData.Fields = append(migrationData.Fields, fieldData)
 
-		if field.IsIndex {
+		if field.IsIndex && !uniqueIndex {
 			migrationData.Indexes = append(migrationData.Indexes, IndexData{
-				Name:    fmt.Sprintf("idx_%s_%s", tableName, field.Name),
-				Columns: []string{field.Name},
+				Name:       fmt.Sprintf("idx_%s_%s", tableName, field.Name),
+				Columns:    []string{field.Name},
+				Method:     indexMethod(field),
+				Concurrent: field.IndexMode == types.IndexConcurrent,
 			})
 		}
 
 		if field.IsReference {
-			refTable := inflection.Plural(strings.TrimSuffix(field.Name, "_id"))
+			refTable := inflection.Plural(strcase.ToSnake(referencedModelName(field)))
 			migrationData.References = append(migrationData.References, ReferenceData{
 				Column:    field.Name,
 				RefTable:  refTable,
@@ -142,7 +368,7 @@ func GenerateMigration(entityName string, fields []types.Field, action types.Act
 		if !hasID {
 			migrationData.Fields = append([]FieldData{{
 				Name:    "id",
-				SQLType: "SERIAL PRIMARY KEY",
+				SQLType: migrationData.PrimaryKey,
 			}}, migrationData.Fields...)
 		}
 		if !hasCreatedAt {
@@ -159,65 +385,57 @@ func GenerateMigration(entityName string, fields []types.Field, action types.Act
 				DefaultValue: "CURRENT_TIMESTAMP",
 			})
 		}
+		if opts.SoftDelete && !hasFieldWithName(fields, "deleted_at") {
+			migrationData.Fields = append(migrationData.Fields, FieldData{
+				Name:       "deleted_at",
+				SQLType:    "TIMESTAMP",
+				IsNullable: true,
+			})
+		}
 	}
 
-	templateName := filepath.Join("templates", "migrations", action.String()+".tmpl")
-
-	if _, err := os.Stat(templateName); os.IsNotExist(err) {
-		return "", fmt.Errorf("template for action %s does not exist: %w", action, err)
-	}
+	return migrationData
+}
 
-	funcMap := template.FuncMap{
-		"toSnake": strcase.ToSnake,
+// getSQLType returns the column type of a field. Field types are validated
+// when the fields are resolved, an unknown one is kept as written.
+func getSQLType(field types.Field, cfg *config.Config) string {
+	if field.IsEnum {
+		return fmt.Sprintf("%s_%s", field.Name, "type")
 	}
 
-	tmpl, err := template.New(filepath.Base(templateName)).Funcs(funcMap).ParseFiles(templateName)
+	t, err := lookupFieldType(field.Type, cfg)
 	if err != nil {
-		return "", fmt.Errorf("error parsing template %s: %w", templateName, err)
+		return field.Type
 	}
-
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, migrationData)
-	if err != nil {
-		return "", fmt.Errorf("error executing migration template: %w", err)
-	}
-
-	return buf.String(), nil
+	return t.SQLType
 }
 
-func getSQLType(field types.Field) string {
-	if field.IsEnum {
-		return fmt.Sprintf("%s_%s", field.Name, "type")
+// enumTypeName returns the name of the enum type of a field, the imported
+// one or <table>_<plural field name>.
+func enumTypeName(tableName string, field types.Field) string {
+	if field.EnumType != "" {
+		return field.EnumType
 	}
+	return fmt.Sprintf("%s_%s", tableName, inflection.Plural(field.Name))
+}
 
-	isArray := strings.HasSuffix(field.Type, "[]")
-	if isArray {
-		field.Type = strings.TrimSuffix(field.Type, "[]")
-	}
-
-	var baseType string
-	switch field.Type {
-	case "int":
-		baseType = "INTEGER"
-	case "string":
-		baseType = "VARCHAR(255)"
-	case "bool":
-		baseType = "BOOLEAN"
-	case "time":
-		baseType = "TIMESTAMP"
-	case "float":
-		baseType = "NUMERIC"
-	case "jsonb":
-		return "JSONB"
-	default:
-		baseType = "VARCHAR(255)"
+func hasEnumWithName(enums []EnumData, name string) bool {
+	for _, enum := range enums {
+		if enum.Name == name {
+			return true
+		}
 	}
+	return false
+}
 
-	if isArray {
-		return baseType + "[]"
+// indexMethod returns the access method of the index of a field. B-tree
+// indexes do not help array operators such as @> and &&, GIN ones do.
+func indexMethod(field types.Field) string {
+	if strings.HasSuffix(field.Type, "[]") {
+		return "GIN"
 	}
-
-	return baseType
+	return ""
 }
 
 func getOnDeleteOption(option string) string {
@@ -235,8 +453,7 @@ func getOnDeleteOption(option string) string {
 	}
 }
 
-func generateMigrationFileName(entityName string, action types.Action) string {
-	timestamp := time.Now().Format("20060102150405")
+func generateMigrationFileName(entityName string, action types.Action, cfg *config.Config, fsys files.FS) (string, error) {
 	var actionStr string
 	switch action {
 	case types.CreateAction:
@@ -247,24 +464,78 @@ func generateMigrationFileName(entityName string, action types.Action) string {
 		actionStr = "remove_fields_from"
 	case types.DropAction:
 		actionStr = "drop"
+	case types.SeedAction:
+		actionStr = "seed"
 	}
 
-	return fmt.Sprintf("%s_%s_%s.sql", timestamp, actionStr, entityName)
+	return newMigrationFileName(actionStr+"_"+entityName, cfg, fsys)
+}
+
+// newMigrationFileName returns the file name of a migration with the next
+// version and the given name.
+func newMigrationFileName(name string, cfg *config.Config, fsys files.FS) (string, error) {
+	version, err := nextMigrationVersion(cfg, fsys)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d_%s.sql", version, name), nil
 }
 
-func saveMigrationToFile(migrationSQL, fileName string, cfg *config.Config) error {
-	migrationDir := cfg.MigrationDir
-	if migrationDir == "" {
-		migrationDir = "migrations"
+// nextMigrationVersion returns the current timestamp as version, or the
+// latest existing version plus one when that is not older, so versions stay
+// unique and ordered even for runs within the same second.
+func nextMigrationVersion(cfg *config.Config, fsys files.FS) (int64, error) {
+	version, err := strconv.ParseInt(time.Now().Format("20060102150405"), 10, 64)
+	if err != nil {
+		return 0, err
 	}
 
-	err := os.MkdirAll(migrationDir, 0755)
+	names, err := fsys.ReadDir(getMigrationDir(cfg))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("error reading migration directory: %w", err)
+	}
+	for _, name := range names {
+		if latest, ok := migrationVersion(name); ok && latest >= version {
+			version = latest + 1
+		}
+	}
+
+	return version, nil
+}
+
+// migrationVersion parses the version prefix of a goose migration file name.
+func migrationVersion(fileName string) (int64, bool) {
+	if !strings.HasSuffix(fileName, ".sql") {
+		return 0, false
+	}
+	prefix, _, found := strings.Cut(fileName, "_")
+	if !found {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+func getMigrationDir(cfg *config.Config) string {
+	if cfg.MigrationDir == "" {
+		return "migrations"
+	}
+	return cfg.MigrationDir
+}
+
+func saveMigrationToFile(migrationSQL, fileName string, cfg *config.Config, fsys files.FS) error {
+	migrationDir := getMigrationDir(cfg)
+
+	err := fsys.MkdirAll(migrationDir, 0755)
 	if err != nil {
 		return fmt.Errorf("error creating migration directory: %w", err)
 	}
 
 	filePath := filepath.Join(migrationDir, fileName)
-	err = os.WriteFile(filePath, []byte(migrationSQL), 0644)
+	err = fsys.WriteFile(filePath, []byte(migrationSQL), 0644)
 	if err != nil {
 		return fmt.Errorf("error writing migration file: %w", err)
 	}
@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codegenex/internal/types"
+)
+
+func TestPrepareMigrationDataBackfill(t *testing.T) {
+	field := types.Field{Name: "published", Type: "bool", Backfill: "false"}
+	tests := []struct {
+		action       types.Action
+		wantNullable bool
+		wantBackfill string
+	}{
+		{action: types.AddFieldsAction, wantNullable: true, wantBackfill: "false"},
+		{action: types.RemoveFieldsAction},
+		{action: types.DropAction},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action.String(), func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			data := prepareMigrationData("post", []types.Field{field}, tt.action, types.EntityOptions{}, m.Config)
+
+			got := data.Fields[0]
+			if got.IsNullable != tt.wantNullable || got.Backfill != tt.wantBackfill || got.BackfillNotNull != (tt.wantBackfill != "") {
+				t.Errorf("field = %+v, want nullable %v and backfill %q", got, tt.wantNullable, tt.wantBackfill)
+			}
+		})
+	}
+}
+
+func TestRemoveBackfilledField(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "post", types.CreateAction, types.EntityOptions{}, "title:string")
+	generate(t, m, "post", types.AddFieldsAction, types.EntityOptions{}, "published:bool:backfill=false")
+
+	if field := loadTestState(t, m).Entity("Post").Field("published"); field == nil || field.Backfill != "" {
+		t.Errorf("recorded field = %+v, want it without the backfill expression", field)
+	}
+
+	generate(t, m, "post", types.RemoveFieldsAction, types.EntityOptions{}, "published")
+	migrations, err := listMigrations(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, migrations[len(migrations)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "ADD COLUMN IF NOT EXISTS published BOOLEAN NOT NULL;") {
+		t.Errorf("Down does not restore the NOT NULL column:\n%s", content)
+	}
+
+	findings, err := m.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finding := range findings {
+		t.Error(finding)
+	}
+}
+
+func TestRemoveFields(t *testing.T) {
+	tests := []struct {
+		name string
+		// fields are removed from posts
+		fields           []string
+		wantUp           []string
+		wantDown         []string
+		wantGoneFromPost []string
+		wantGoneFromUser []string
+	}{
+		{
+			name:   "reference",
+			fields: []string{"user_id"},
+			wantUp: []string{
+				"ALTER TABLE posts DROP CONSTRAINT IF EXISTS fk_posts_user_id;",
+				"DROP INDEX IF EXISTS idx_posts_user_id;",
+				"ALTER TABLE posts DROP COLUMN IF EXISTS user_id;",
+			},
+			wantDown: []string{
+				"ADD COLUMN IF NOT EXISTS user_id INTEGER NULL;",
+				"CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts (user_id);",
+				"ADD CONSTRAINT fk_posts_user_id\nFOREIGN KEY (user_id) REFERENCES users(id)\nON DELETE SET NULL;",
+			},
+			wantGoneFromPost: []string{"UserId", "Users"},
+			wantGoneFromUser: []string{"Posts"},
+		},
+		{
+			name:   "enum",
+			fields: []string{"status"},
+			wantUp: []string{
+				"ALTER TABLE posts DROP COLUMN IF EXISTS status;",
+				"DROP TYPE IF EXISTS posts_statuses;",
+			},
+			wantDown: []string{
+				"CREATE TYPE posts_statuses AS ENUM (\n            'draft',\n            'published'\n        );",
+				"ADD COLUMN IF NOT EXISTS status posts_statuses NOT NULL DEFAULT 'draft';",
+			},
+			wantGoneFromPost: []string{"Status", "PostStatusType"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			generate(t, m, "user", types.CreateAction, types.EntityOptions{}, "name:string")
+			generate(t, m, "post", types.CreateAction, types.EntityOptions{}, "title:string", "status:enum[draft,published]:default='draft'")
+			generate(t, m, "post", types.AddFieldsAction, types.EntityOptions{}, "user_id:int:ref=nullify:null:i=blocking")
+
+			// only the names are given, the rest comes from the state
+			generate(t, m, "post", types.RemoveFieldsAction, types.EntityOptions{}, tt.fields...)
+
+			migrations, err := listMigrations(m.Config, m.Files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content, err := m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, migrations[len(migrations)-1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			up, down, err := splitMigration(string(content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.wantUp {
+				if !strings.Contains(up, want) {
+					t.Errorf("up section lacks %q:\n%s", want, up)
+				}
+			}
+			for _, want := range tt.wantDown {
+				if !strings.Contains(down, want) {
+					t.Errorf("down section lacks %q:\n%s", want, down)
+				}
+			}
+
+			for model, gone := range map[string][]string{"Post": tt.wantGoneFromPost, "User": tt.wantGoneFromUser} {
+				content, err := m.Files.ReadFile(getModelFilePath(model, m.Config))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, name := range gone {
+					if strings.Contains(string(content), name) {
+						t.Errorf("model %s still contains %s:\n%s", model, name, content)
+					}
+				}
+				if strings.Contains(string(content), "\n\n\t") {
+					t.Errorf("model %s has blank lines where fields were removed:\n%s", model, content)
+				}
+			}
+			checkModels(t, m)
+
+			findings, err := m.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, finding := range findings {
+				t.Error(finding)
+			}
+		})
+	}
+}
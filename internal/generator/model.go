@@ -7,12 +7,14 @@ import (
 	"go/format"
 	"go/parser"
 	"go/token"
-	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
 	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
 	"codegenex/internal/types"
 
 	"github.com/iancoleman/strcase"
@@ -20,7 +22,9 @@ import (
 )
 
 type ModelData struct {
+	Package            string
 	Name               string
+	SoftDelete         bool
 	Fields             []ModelField
 	Imports            []string
 	HasManyRelations   []Relation
@@ -40,26 +44,31 @@ type Relation struct {
 	FieldName string
 }
 
-func GenerateModel(entityName string, fields []types.Field, action types.Action) error {
-	cfg := config.GetConfig()
+func GenerateModel(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions, cfg *config.Config, fsys files.FS) error {
 	modelName := inflection.Singular(strcase.ToCamel(entityName))
 
 	switch action {
 	case types.CreateAction:
-		return createModel(modelName, fields, cfg)
+		return createModel(modelName, fields, opts, cfg, fsys)
 	case types.AddFieldsAction:
-		return addFieldsToModel(modelName, fields, cfg)
+		return addFieldsToModel(modelName, fields, cfg, fsys)
 	case types.RemoveFieldsAction:
-		return removeFieldsFromModel(modelName, fields, cfg)
+		return removeFieldsFromModel(modelName, fields, cfg, fsys)
 	case types.DropAction:
-		return removeModel(modelName, cfg)
+		return removeModel(modelName, cfg, fsys)
 	default:
 		return fmt.Errorf("unknown action: %s", action)
 	}
 }
 
-func createModel(modelName string, fields []types.Field, cfg *config.Config) error {
-	modelData := prepareModelData(modelName, fields)
+func createModel(modelName string, fields []types.Field, opts types.EntityOptions, cfg *config.Config, fsys files.FS) error {
+	modelData := prepareModelData(modelName, fields, opts, cfg)
+	return renderModel(modelData, cfg, fsys)
+}
+
+func renderModel(modelData ModelData, cfg *config.Config, fsys files.FS) error {
+	modelName := modelData.Name
+	modelData.Package = cfg.ModelPackage
 
 	funcMap := template.FuncMap{
 		"toCamel":   strcase.ToCamel,
@@ -67,9 +76,9 @@ func createModel(modelName string, fields []types.Field, cfg *config.Config) err
 		"pluralize": inflection.Plural,
 	}
 
-	tmpl, err := template.New("model.tmpl").Funcs(funcMap).ParseFiles("templates/models/model.tmpl")
+	tmpl, err := loadTemplate("models/model.tmpl", funcMap, cfg, fsys)
 	if err != nil {
-		return fmt.Errorf("error parsing model template: %w", err)
+		return err
 	}
 
 	var buf bytes.Buffer
@@ -78,12 +87,18 @@ func createModel(modelName string, fields []types.Field, cfg *config.Config) err
 		return fmt.Errorf("error executing model template: %w", err)
 	}
 
-	err = saveModelToFile(modelName, buf.Bytes(), cfg)
+	// format right away so later edits through go/format only show real changes
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error formatting model %s: %w", modelName, err)
+	}
+
+	err = saveModelToFile(modelName, content, cfg, fsys)
 	if err != nil {
 		return err
 	}
 
-	err = updateReferencedModel(modelName, modelData.BelongsToRelations, cfg)
+	err = updateReferencedModel(modelName, modelData.BelongsToRelations, cfg, fsys)
 	if err != nil {
 		return fmt.Errorf("error updating referenced models: %w", err)
 	}
@@ -91,9 +106,10 @@ func createModel(modelName string, fields []types.Field, cfg *config.Config) err
 	return nil
 }
 
-func prepareModelData(modelName string, fields []types.Field) ModelData {
+func prepareModelData(modelName string, fields []types.Field, opts types.EntityOptions, cfg *config.Config) ModelData {
 	modelData := ModelData{
 		Name:               modelName,
+		SoftDelete:         opts.SoftDelete,
 		Fields:             make([]ModelField, 0),
 		Enums:              make([]EnumData, 0),
 		HasManyRelations:   make([]Relation, 0),
@@ -108,22 +124,18 @@ func prepareModelData(modelName string, fields []types.Field) ModelData {
 	if !hasFieldWithName(fields, "id") {
 		modelData.Fields = append(modelData.Fields, ModelField{
 			Name: "ID",
-			Type: "int64",
+			Type: getPrimaryKey(opts.PrimaryKey).GoType,
 		})
 	}
 
 	for _, field := range fields {
 		modelField := ModelField{
 			Name: strcase.ToCamel(field.Name),
-			Type: getGoType(field),
-		}
-
-		if field.Type == "time" {
-			needsTimeImport = true
+			Type: getGoType(field, cfg),
 		}
 
 		if field.IsEnum {
-			enumName := fmt.Sprintf("%s%sType", modelName, strcase.ToCamel(field.Name))
+			enumName := modelEnumType(modelName, field.Name)
 			modelField.Type = enumName
 			modelData.Enums = append(modelData.Enums, EnumData{
 				Name:   enumName,
@@ -134,7 +146,7 @@ func prepareModelData(modelName string, fields []types.Field) ModelData {
 		modelData.Fields = append(modelData.Fields, modelField)
 
 		if field.IsReference {
-			referencedModel := inflection.Singular(strcase.ToCamel(strings.TrimSuffix(field.Name, "_id")))
+			referencedModel := referencedModelName(field)
 			relationName := inflection.Plural(referencedModel)
 			modelData.HasManyRelations = append(modelData.HasManyRelations, Relation{
 				ModelName: referencedModel,
@@ -164,14 +176,79 @@ func prepareModelData(modelName string, fields []types.Field) ModelData {
 		})
 		needsTimeImport = true
 	}
+	if opts.SoftDelete && !hasFieldWithName(fields, "deleted_at") {
+		modelData.Fields = append(modelData.Fields, ModelField{
+			Name: "DeletedAt",
+			Type: "*time.Time",
+		})
+		needsTimeImport = true
+	}
 
+	imports := fieldTypeImports(fields, cfg)
 	if needsTimeImport {
-		modelData.Imports = append(modelData.Imports, "time")
+		imports = appendUnique(imports, "time")
+		sort.Strings(imports)
 	}
+	modelData.Imports = append(modelData.Imports, imports...)
 
 	return modelData
 }
 
+// referencedModelName returns the model a reference field points to, derived
+// from the <model>_id column name unless set explicitly.
+func referencedModelName(field types.Field) string {
+	if field.ReferencedModel != "" {
+		return field.ReferencedModel
+	}
+	return inflection.Singular(strcase.ToCamel(strings.TrimSuffix(field.Name, "_id")))
+}
+
+// modelEnumType is the Go type of an enum field of the model.
+func modelEnumType(modelName, fieldName string) string {
+	return fmt.Sprintf("%s%sType", modelName, strcase.ToCamel(fieldName))
+}
+
+// detachedModels returns the models the removed reference fields point to
+// that are no longer related to the model once the fields are gone: no
+// other field of the model references them and they do not reference the
+// model themselves.
+func detachedModels(modelName string, removed []types.Field, s *state.State) []string {
+	entity := s.Entity(modelName)
+	remaining := make([]types.Field, 0)
+	if entity != nil {
+		for _, field := range entity.Fields {
+			if !hasFieldWithName(removed, field.Name) {
+				remaining = append(remaining, field)
+			}
+		}
+	}
+
+	models := make([]string, 0)
+	for _, field := range removed {
+		if !field.IsReference {
+			continue
+		}
+		referencedModel := referencedModelName(field)
+		if referencesModel(remaining, referencedModel) {
+			continue
+		}
+		if referenced := s.Entity(referencedModel); referenced != nil && referencedModel != modelName && referencesModel(referenced.Fields, modelName) {
+			continue
+		}
+		models = appendUnique(models, referencedModel)
+	}
+	return models
+}
+
+func referencesModel(fields []types.Field, modelName string) bool {
+	for _, field := range fields {
+		if field.IsReference && referencedModelName(field) == modelName {
+			return true
+		}
+	}
+	return false
+}
+
 func hasFieldWithName(fields []types.Field, name string) bool {
 	for _, field := range fields {
 		if field.Name == name {
@@ -181,13 +258,13 @@ func hasFieldWithName(fields []types.Field, name string) bool {
 	return false
 }
 
-func addFieldsToModel(modelName string, newFields []types.Field, cfg *config.Config) error {
+func addFieldsToModel(modelName string, newFields []types.Field, cfg *config.Config, fsys files.FS) error {
 	filePath := getModelFilePath(modelName, cfg)
 
 	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+	node, err := parseGoFile(fset, filePath, fsys)
 	if err != nil {
-		return fmt.Errorf("error parsing file %s: %w", filePath, err)
+		return err
 	}
 
 	var structDecl *ast.TypeSpec
@@ -222,13 +299,13 @@ func addFieldsToModel(modelName string, newFields []types.Field, cfg *config.Con
 		if !existingFields[fieldName] {
 			newField := &ast.Field{
 				Names: []*ast.Ident{ast.NewIdent(fieldName)},
-				Type:  ast.NewIdent(getGoType(field)),
+				Type:  ast.NewIdent(getGoType(field, cfg)),
 			}
 			structType.Fields.List = append(structType.Fields.List, newField)
 		}
 
 		if field.IsReference {
-			referencedModel := inflection.Singular(strcase.ToCamel(strings.TrimSuffix(field.Name, "_id")))
+			referencedModel := referencedModelName(field)
 			relationName := inflection.Plural(referencedModel)
 			if !existingFields[relationName] {
 				relationField := &ast.Field{
@@ -246,19 +323,21 @@ func addFieldsToModel(modelName string, newFields []types.Field, cfg *config.Con
 		}
 	}
 
+	syncTypeImports(node, cfg)
+
 	var buf bytes.Buffer
 	err = format.Node(&buf, fset, node)
 	if err != nil {
 		return fmt.Errorf("error formatting updated file: %w", err)
 	}
 
-	err = saveModelToFile(modelName, buf.Bytes(), cfg)
+	err = saveModelToFile(modelName, buf.Bytes(), cfg, fsys)
 	if err != nil {
 		return err
 	}
 
 	for _, ref := range referencesToUpdate {
-		err = updateReferencedModel(modelName, []Relation{ref}, cfg)
+		err = updateReferencedModel(modelName, []Relation{ref}, cfg, fsys)
 		if err != nil {
 			return fmt.Errorf("error updating referenced model %s: %w", ref.ModelName, err)
 		}
@@ -267,13 +346,23 @@ func addFieldsToModel(modelName string, newFields []types.Field, cfg *config.Con
 	return nil
 }
 
-func removeFieldsFromModel(modelName string, fieldsToRemove []types.Field, cfg *config.Config) error {
+// removeFieldsFromModel removes the fields from the model together with the
+// declarations of their enum types. Relation fields between the model and
+// the models that the removed references detach from it are removed on both
+// sides.
+func removeFieldsFromModel(modelName string, fieldsToRemove []types.Field, cfg *config.Config, fsys files.FS) error {
 	filePath := getModelFilePath(modelName, cfg)
 
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+	detached := detachedModels(modelName, fieldsToRemove, s)
+
 	fset := token.NewFileSet()
-	node, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+	node, err := parseGoFile(fset, filePath, fsys)
 	if err != nil {
-		return fmt.Errorf("error parsing file %s: %w", filePath, err)
+		return err
 	}
 
 	// find struct def
@@ -297,10 +386,15 @@ func removeFieldsFromModel(modelName string, fieldsToRemove []types.Field, cfg *
 	}
 
 	fieldsToRemoveMap := make(map[string]bool)
+	enumTypes := make(map[string]bool)
 	for _, field := range fieldsToRemove {
 		fieldsToRemoveMap[strcase.ToCamel(field.Name)] = true
+		if field.IsEnum {
+			enumTypes[modelEnumType(modelName, field.Name)] = true
+		}
 	}
 
+	fields := structType.Fields.List
 	newFields := make([]*ast.Field, 0)
 	for _, field := range structType.Fields.List {
 		if len(field.Names) > 0 && !fieldsToRemoveMap[field.Names[0].Name] {
@@ -309,23 +403,124 @@ func removeFieldsFromModel(modelName string, fieldsToRemove []types.Field, cfg *
 	}
 	structType.Fields.List = newFields
 
+	for _, relatedModel := range detached {
+		removeRelationFields(structType, relatedModel, nil)
+	}
+	mergeRemovedLines(fset, fields, structType.Fields.List)
+	removeEnumDecls(node, enumTypes)
+
+	syncTypeImports(node, cfg)
+
 	var buf bytes.Buffer
 	err = format.Node(&buf, fset, node)
 	if err != nil {
 		return fmt.Errorf("error formatting updated file: %w", err)
 	}
 
-	return saveModelToFile(modelName, buf.Bytes(), cfg)
+	err = saveModelToFile(modelName, buf.Bytes(), cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	for _, relatedModel := range detached {
+		if relatedModel == modelName {
+			continue
+		}
+		err = removeRelationToModel(relatedModel, modelName, cfg, fsys)
+		if err != nil {
+			return fmt.Errorf("error updating referenced model %s: %w", relatedModel, err)
+		}
+	}
+	return nil
 }
 
-func removeModel(modelName string, cfg *config.Config) error {
+// removeRelationToModel removes the relation fields to relatedModel from
+// the model, the counterpart of updateReferencedModel.
+func removeRelationToModel(modelName, relatedModel string, cfg *config.Config, fsys files.FS) error {
 	filePath := getModelFilePath(modelName, cfg)
-	err := os.Remove(filePath)
+	if !fsys.Exists(filePath) {
+		return nil
+	}
+
+	fset := token.NewFileSet()
+	node, err := parseGoFile(fset, filePath, fsys)
 	if err != nil {
-		return fmt.Errorf("error removing model file %s: %w", filePath, err)
+		return err
 	}
-	fmt.Printf("Model file removed: %s\n", filePath)
-	return nil
+
+	changed := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == modelName {
+			if structType, ok := ts.Type.(*ast.StructType); ok {
+				fields := structType.Fields.List
+				changed = removeRelationFields(structType, relatedModel, nil)
+				mergeRemovedLines(fset, fields, structType.Fields.List)
+			}
+			return false
+		}
+		return true
+	})
+	if !changed {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	err = format.Node(&buf, fset, node)
+	if err != nil {
+		return fmt.Errorf("error formatting updated file: %w", err)
+	}
+	return saveModelToFile(modelName, buf.Bytes(), cfg, fsys)
+}
+
+// setModelFieldType changes the Go type of an existing model field in place.
+func setModelFieldType(modelName string, field types.Field, cfg *config.Config, fsys files.FS) error {
+	filePath := getModelFilePath(modelName, cfg)
+
+	fset := token.NewFileSet()
+	node, err := parseGoFile(fset, filePath, fsys)
+	if err != nil {
+		return err
+	}
+
+	var structDecl *ast.TypeSpec
+	ast.Inspect(node, func(n ast.Node) bool {
+		if ts, ok := n.(*ast.TypeSpec); ok && ts.Name.Name == modelName {
+			structDecl = ts
+			return false
+		}
+		return true
+	})
+
+	if structDecl == nil {
+		return fmt.Errorf("struct %s not found in file %s", modelName, filePath)
+	}
+
+	structType, ok := structDecl.Type.(*ast.StructType)
+	if !ok {
+		return fmt.Errorf("%s is not a struct type", modelName)
+	}
+
+	fieldName := strcase.ToCamel(field.Name)
+	found := false
+	for _, modelField := range structType.Fields.List {
+		if len(modelField.Names) > 0 && modelField.Names[0].Name == fieldName {
+			modelField.Type = ast.NewIdent(getGoType(field, cfg))
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("field %s not found in struct %s", fieldName, modelName)
+	}
+
+	syncTypeImports(node, cfg)
+
+	var buf bytes.Buffer
+	err = format.Node(&buf, fset, node)
+	if err != nil {
+		return fmt.Errorf("error formatting updated file: %w", err)
+	}
+
+	return saveModelToFile(modelName, buf.Bytes(), cfg, fsys)
 }
 
 func getModelFilePath(modelName string, cfg *config.Config) string {
@@ -337,32 +532,66 @@ func getModelFilePath(modelName string, cfg *config.Config) string {
 	return filepath.Join(modelDir, fileName)
 }
 
-func saveModelToFile(modelName string, content []byte, cfg *config.Config) error {
+func saveModelToFile(modelName string, content []byte, cfg *config.Config, fsys files.FS) error {
 	filePath := getModelFilePath(modelName, cfg)
 
 	modelDir := filepath.Dir(filePath)
-	err := os.MkdirAll(modelDir, 0755)
+	err := fsys.MkdirAll(modelDir, 0755)
 	if err != nil {
 		return fmt.Errorf("error creating model directory: %w", err)
 	}
 
-	err = os.WriteFile(filePath, content, 0644)
+	err = fsys.WriteFile(filePath, content, 0644)
 	if err != nil {
 		return fmt.Errorf("error writing model file: %w", err)
 	}
 
 	fmt.Printf("Model file updated: %s\n", filePath)
+
+	if cfg.ArrayType == config.ArrayGeneric && bytes.Contains(content, []byte("Array[")) {
+		return saveArrayType(cfg, fsys)
+	}
 	return nil
 }
 
-func updateReferencedModel(currentModel string, relations []Relation, cfg *config.Config) error {
+// saveArrayType writes the Array type generic array fields use into the
+// model directory unless the file exists, which keeps local changes.
+func saveArrayType(cfg *config.Config, fsys files.FS) error {
+	filePath := filepath.Join(cfg.ModelDir, "array.go")
+	if fsys.Exists(filePath) {
+		return nil
+	}
+
+	tmpl, err := loadTemplate("models/array.tmpl", template.FuncMap{}, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, ModelData{Package: cfg.ModelPackage})
+	if err != nil {
+		return fmt.Errorf("error executing array template: %w", err)
+	}
+	content, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error formatting array type: %w", err)
+	}
+
+	err = fsys.WriteFile(filePath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing array type: %w", err)
+	}
+	fmt.Printf("Array type generated: %s\n", filePath)
+	return nil
+}
+
+func updateReferencedModel(currentModel string, relations []Relation, cfg *config.Config, fsys files.FS) error {
 	for _, relation := range relations {
 		filePath := getModelFilePath(relation.ModelName, cfg)
 
 		fset := token.NewFileSet()
-		node, err := parser.ParseFile(fset, filePath, nil, parser.ParseComments)
+		node, err := parseGoFile(fset, filePath, fsys)
 		if err != nil {
-			return fmt.Errorf("error parsing file %s: %w", filePath, err)
+			return err
 		}
 
 		var structDecl *ast.TypeSpec
@@ -406,7 +635,7 @@ func updateReferencedModel(currentModel string, relations []Relation, cfg *confi
 				return fmt.Errorf("error formatting updated file: %w", err)
 			}
 
-			err = saveModelToFile(relation.ModelName, buf.Bytes(), cfg)
+			err = saveModelToFile(relation.ModelName, buf.Bytes(), cfg, fsys)
 			if err != nil {
 				return fmt.Errorf("error saving updated file: %w", err)
 			}
@@ -416,37 +645,30 @@ func updateReferencedModel(currentModel string, relations []Relation, cfg *confi
 	return nil
 }
 
-func getGoType(field types.Field) string {
-	if field.IsEnum {
-		return "string"
+// parseGoFile parses a Go source file read through fsys.
+func parseGoFile(fset *token.FileSet, filePath string, fsys files.FS) (*ast.File, error) {
+	src, err := fsys.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %w", filePath, err)
 	}
 
-	isArray := strings.HasSuffix(field.Type, "[]")
-	if isArray {
-		field.Type = strings.TrimSuffix(field.Type, "[]")
-	}
-
-	var baseType string
-	switch field.Type {
-	case "int":
-		baseType = "int64"
-	case "string":
-		baseType = "string"
-	case "bool":
-		baseType = "bool"
-	case "time":
-		baseType = "time.Time"
-	case "float":
-		baseType = "float64"
-	case "jsonb":
-		baseType = "map[string]interface{}"
-	default:
-		baseType = "interface{}"
+	node, err := parser.ParseFile(fset, filePath, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file %s: %w", filePath, err)
 	}
+	return node, nil
+}
 
-	if isArray {
-		return "[]" + baseType
+// getGoType returns the model field type of a field. Field types are
+// validated when the fields are resolved, an unknown one is kept as written.
+func getGoType(field types.Field, cfg *config.Config) string {
+	if field.IsEnum {
+		return "string"
 	}
 
-	return baseType
+	t, err := lookupFieldType(field.Type, cfg)
+	if err != nil {
+		return field.Type
+	}
+	return t.GoType
 }
@@ -0,0 +1,166 @@
+package generator
+
+import (
+	"fmt"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+// ResolveEntity completes the options with values from the state and the
+// config and gives reference fields the column type of the primary key
+// they point to. Options given on the command line only apply to create,
+// later actions use what was recorded for the entity.
+func ResolveEntity(entityName string, action types.Action, fields []types.Field, opts types.EntityOptions, cfg *config.Config, fsys files.FS) ([]types.Field, types.EntityOptions, error) {
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	opts, err = resolveOptions(entityName, action, opts, s, cfg)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	err = checkBackfill(action, fields)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	switch action {
+	case types.DropAction:
+		fields = prepareDrop(entityName, fields, s)
+	case types.RemoveFieldsAction:
+		fields, err = recordedFields(entityName, fields, s)
+		if err != nil {
+			return nil, opts, err
+		}
+	}
+
+	fields, err = resolveFields(entityName, fields, opts, s, cfg)
+	if err != nil {
+		return nil, opts, err
+	}
+	return fields, opts, nil
+}
+
+// checkBackfill rejects the backfill option outside add_fields, the only
+// action that adds columns to tables which may have rows.
+func checkBackfill(action types.Action, fields []types.Field) error {
+	for _, field := range fields {
+		if field.Backfill != "" && action != types.AddFieldsAction {
+			return fmt.Errorf("backfill of field %s only applies to add_fields", field.Name)
+		}
+	}
+	return nil
+}
+
+// recordedFields replaces the fields to remove with their recorded
+// definitions, so the migration drops and restores everything the field
+// brought along: its index, foreign key and enum type. An index mode given
+// on the command line still applies.
+func recordedFields(entityName string, fields []types.Field, s *state.State) ([]types.Field, error) {
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+	entity := s.Entity(modelName)
+	if entity == nil {
+		fmt.Printf("Warning: entity %s is missing from the state, the Down section restores the fields as given\n", modelName)
+		return fields, nil
+	}
+
+	recorded := make([]types.Field, 0, len(fields))
+	for _, field := range fields {
+		previous := entity.Field(field.Name)
+		if previous == nil {
+			return nil, fmt.Errorf("entity %s has no field %s", modelName, field.Name)
+		}
+		if changeOfColumn(s, modelName, field.Name) != nil {
+			return nil, fmt.Errorf("field %s of entity %s has a pending change, contract it first", field.Name, modelName)
+		}
+		removed := *previous
+		if field.IndexMode != "" {
+			removed.IndexMode = field.IndexMode
+		}
+		recorded = append(recorded, removed)
+	}
+	return recorded, nil
+}
+
+func resolveOptions(entityName string, action types.Action, opts types.EntityOptions, s *state.State, cfg *config.Config) (types.EntityOptions, error) {
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+	tableName := inflection.Plural(strcase.ToSnake(entityName))
+
+	if action != types.CreateAction {
+		if opts.PrimaryKey != "" {
+			return opts, fmt.Errorf("the primary key strategy can only be set when creating an entity")
+		}
+		if opts.SoftDelete {
+			return opts, fmt.Errorf("soft delete can only be enabled when creating an entity")
+		}
+		opts.SoftDelete = entitySoftDelete(modelName, s, cfg)
+	} else if !opts.SoftDelete {
+		opts.SoftDelete = cfg.EntitySoftDelete(tableName)
+	}
+
+	if opts.PrimaryKey == "" {
+		opts.PrimaryKey = entityPrimaryKey(modelName, s, cfg)
+	}
+	err := validatePrimaryKey(opts.PrimaryKey)
+	if err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// resolveFields checks the field types, sets the type of reference fields
+// to the column type of the primary key of the referenced entity and
+// settles the index mode of the fields that get an index from the config
+// default.
+func resolveFields(entityName string, fields []types.Field, opts types.EntityOptions, s *state.State, cfg *config.Config) ([]types.Field, error) {
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+
+	err := validateFieldTypes(fields, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]types.Field, len(fields))
+	for i, field := range fields {
+		if field.IsReference {
+			referencedModel := referencedModelName(field)
+			strategy := opts.PrimaryKey
+			if referencedModel != modelName {
+				strategy = entityPrimaryKey(referencedModel, s, cfg)
+			}
+			field.Type = getPrimaryKey(strategy).RefType
+		}
+
+		// unique fields of soft delete entities get a partial unique index
+		indexed := field.IsIndex || (field.IsUnique && opts.SoftDelete)
+		switch field.IndexMode {
+		case "":
+			if indexed && cfg.ConcurrentIndexes {
+				field.IndexMode = types.IndexConcurrent
+			}
+		case types.IndexConcurrent, types.IndexBlocking:
+		default:
+			return nil, fmt.Errorf("unknown index mode %q of field %s, expected %s or %s", field.IndexMode, field.Name, types.IndexConcurrent, types.IndexBlocking)
+		}
+
+		resolved[i] = field
+	}
+	return resolved, nil
+}
+
+// entitySoftDelete reports whether an existing entity uses soft deletes,
+// as recorded in the state or otherwise configured.
+func entitySoftDelete(modelName string, s *state.State, cfg *config.Config) bool {
+	if entity := s.Entity(modelName); entity != nil {
+		return entity.SoftDelete
+	}
+	return cfg.EntitySoftDelete(inflection.Plural(strcase.ToSnake(modelName)))
+}
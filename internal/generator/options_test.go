@@ -0,0 +1,69 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"codegenex/internal/parser"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+)
+
+func TestResolveFieldsIndexMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		field      string
+		softDelete bool
+		want       string
+	}{
+		{name: "index", config: `{"concurrent_indexes": true}`, field: "nick:string:i", want: types.IndexConcurrent},
+		{name: "index without default", config: `{}`, field: "nick:string:i", want: ""},
+		{name: "blocking index", config: `{"concurrent_indexes": true}`, field: "nick:string:i=blocking", want: types.IndexBlocking},
+		{name: "soft delete unique", config: `{"concurrent_indexes": true}`, field: "nick:string:unique:null", softDelete: true, want: types.IndexConcurrent},
+		{name: "unique constraint", config: `{"concurrent_indexes": true}`, field: "nick:string:unique:null", want: ""},
+		{name: "plain field", config: `{"concurrent_indexes": true}`, field: "nick:string:null", softDelete: true, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, tt.config)
+			fields, err := parser.ParseFields([]string{tt.field})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts := types.EntityOptions{PrimaryKey: "serial", SoftDelete: tt.softDelete}
+
+			resolved, err := resolveFields("account", fields, opts, &state.State{}, m.Config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved[0].IndexMode != tt.want {
+				t.Errorf("index mode = %q, want %q", resolved[0].IndexMode, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddUniqueFieldToSoftDeleteEntityBuildsIndexConcurrently(t *testing.T) {
+	m := newTestManager(t, `{"concurrent_indexes": true}`)
+	generate(t, m, "account", types.CreateAction, types.EntityOptions{SoftDelete: true}, "name:string")
+	generate(t, m, "account", types.AddFieldsAction, types.EntityOptions{}, "nick:string:unique:null")
+
+	findings, err := m.Lint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, finding := range findings {
+		t.Error(finding)
+	}
+
+	migrations, err := listMigrations(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := migrations[len(migrations)-1]
+	if !strings.HasSuffix(last, "_add_indexes_to_account.sql") {
+		t.Fatalf("last migration = %s, want the concurrent index migration", last)
+	}
+}
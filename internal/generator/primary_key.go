@@ -0,0 +1,60 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/state"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+// primaryKeyStrategy describes how the id column of a table is generated.
+type primaryKeyStrategy struct {
+	// SQLType is the id column definition in CREATE TABLE.
+	SQLType string
+	// GoType is the type of the model ID field.
+	GoType string
+	// RefType is the field type of <model>_id columns referencing the table.
+	RefType string
+}
+
+var primaryKeyStrategies = map[string]primaryKeyStrategy{
+	"serial":    {SQLType: "SERIAL PRIMARY KEY", GoType: "int64", RefType: "int"},
+	"bigserial": {SQLType: "BIGSERIAL PRIMARY KEY", GoType: "int64", RefType: "bigint"},
+	"identity":  {SQLType: "BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY", GoType: "int64", RefType: "bigint"},
+	"uuid":      {SQLType: "UUID PRIMARY KEY DEFAULT gen_random_uuid()", GoType: "string", RefType: "uuid"},
+	"ulid":      {SQLType: "CHAR(26) PRIMARY KEY", GoType: "string", RefType: "ulid"},
+}
+
+// getPrimaryKey returns the strategy with the given name, serial when empty.
+func getPrimaryKey(name string) primaryKeyStrategy {
+	if strategy, ok := primaryKeyStrategies[name]; ok {
+		return strategy
+	}
+	return primaryKeyStrategies["serial"]
+}
+
+func validatePrimaryKey(name string) error {
+	if _, ok := primaryKeyStrategies[name]; ok {
+		return nil
+	}
+	names := make([]string, 0, len(primaryKeyStrategies))
+	for strategy := range primaryKeyStrategies {
+		names = append(names, strategy)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("unknown primary key strategy %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// entityPrimaryKey returns the strategy of an existing entity: the one
+// recorded in the state, otherwise the configured one.
+func entityPrimaryKey(modelName string, s *state.State, cfg *config.Config) string {
+	if entity := s.Entity(modelName); entity != nil && entity.PrimaryKey != "" {
+		return entity.PrimaryKey
+	}
+	return cfg.EntityPrimaryKey(inflection.Plural(strcase.ToSnake(modelName)))
+}
@@ -0,0 +1,350 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+type SeedData struct {
+	TableName string
+	Columns   []string
+	Rows      [][]string
+	Keys      []string
+}
+
+// seedRow holds the raw values of a single data row; a nil value means NULL.
+type seedRow map[string]*string
+
+func GenerateAndSaveSeed(entityName, dataFile string, cfg *config.Config, fsys files.FS) error {
+	migrationSQL, err := GenerateSeed(entityName, dataFile, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error generating seed migration: %w", err)
+	}
+
+	fileName, err := generateMigrationFileName(entityName, types.SeedAction, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	err = saveMigrationToFile(migrationSQL, fileName, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error saving migration: %w", err)
+	}
+
+	fmt.Printf("Migration file generated: %s\n", fileName)
+	return nil
+}
+
+func GenerateSeed(entityName, dataFile string, cfg *config.Config, fsys files.FS) (string, error) {
+	tableName := inflection.Plural(strcase.ToSnake(entityName))
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+
+	info, err := inspectModel(modelName, cfg, fsys)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return "", err
+	}
+	entity := s.Entity(modelName)
+
+	columns, rows, err := readSeedFile(dataFile, fsys)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("seed file %s contains no rows", dataFile)
+	}
+
+	seedData := SeedData{
+		TableName: tableName,
+		Columns:   columns,
+		Rows:      make([][]string, 0, len(rows)),
+		Keys:      make([]string, 0, len(rows)),
+	}
+
+	modelColumns := make([]modelColumn, 0, len(columns))
+	nullable := make([]bool, 0, len(columns))
+	for _, name := range columns {
+		column, ok := info.column(name)
+		if !ok {
+			return "", fmt.Errorf("unknown field %s for entity %s", name, entityName)
+		}
+		modelColumns = append(modelColumns, column)
+		nullable = append(nullable, seedNullable(entity, column))
+	}
+
+	key, err := seedKey(entity, columns)
+	if err != nil {
+		return "", err
+	}
+
+	for i, row := range rows {
+		values := make([]string, 0, len(columns))
+		literals := make(map[string]string, len(columns))
+		for j, name := range columns {
+			raw, present := row[name]
+			if !present {
+				values = append(values, "DEFAULT")
+				continue
+			}
+
+			if raw == nil && !nullable[j] {
+				return "", fmt.Errorf("row %d, field %s: value is NULL but the field is NOT NULL", i+1, name)
+			}
+			literal, err := seedLiteral(modelColumns[j], raw)
+			if err != nil {
+				return "", fmt.Errorf("row %d, field %s: %w", i+1, name, err)
+			}
+			values = append(values, literal)
+			literals[name] = literal
+		}
+		seedData.Rows = append(seedData.Rows, values)
+
+		literal, ok := literals[key]
+		if !ok || literal == "NULL" {
+			return "", fmt.Errorf("row %d has no value of %s to identify it in the down migration", i+1, key)
+		}
+		seedData.Keys = append(seedData.Keys, fmt.Sprintf("%s = %s", key, literal))
+	}
+
+	funcMap := template.FuncMap{
+		"join": strings.Join,
+	}
+
+	tmpl, err := loadTemplate("migrations/"+types.SeedAction.String()+".tmpl", funcMap, cfg, fsys)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, seedData)
+	if err != nil {
+		return "", fmt.Errorf("error executing seed template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// seedNullable reports whether a column accepts NULL. The state records the
+// nullability of every field; without it only pointer fields of the model
+// are taken as nullable.
+func seedNullable(entity *state.Entity, column modelColumn) bool {
+	if entity != nil {
+		if field := entity.Field(column.Name); field != nil {
+			return field.IsNullable
+		}
+	}
+	return column.IsPointer
+}
+
+// seedKey returns the column the down migration deletes the seeded rows by:
+// the primary key, or else the first unique field of the state among the
+// columns.
+func seedKey(entity *state.Entity, columns []string) (string, error) {
+	for _, name := range columns {
+		if name == "id" {
+			return name, nil
+		}
+	}
+	if entity != nil {
+		for _, name := range columns {
+			if field := entity.Field(name); field != nil && field.IsUnique {
+				return name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("seed data needs an id or a unique field to identify its rows in the down migration")
+}
+
+// readSeedFile loads rows from a CSV file with a header line or from a JSON
+// array of objects. Columns are returned in the order they first appear.
+func readSeedFile(dataFile string, fsys files.FS) ([]string, []seedRow, error) {
+	content, err := fsys.ReadFile(dataFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading seed file %s: %w", dataFile, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(dataFile)) {
+	case ".csv":
+		return readSeedCSV(content)
+	case ".json":
+		return readSeedJSON(content)
+	default:
+		return nil, nil, fmt.Errorf("unsupported seed file format: %s", dataFile)
+	}
+}
+
+func readSeedCSV(content []byte) ([]string, []seedRow, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing csv: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("csv file has no header")
+	}
+
+	columns := make([]string, 0, len(records[0]))
+	for _, name := range records[0] {
+		columns = append(columns, strings.TrimSpace(name))
+	}
+
+	rows := make([]seedRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(seedRow, len(columns))
+		for i, name := range columns {
+			value := record[i]
+			if value == "" {
+				row[name] = nil
+				continue
+			}
+			row[name] = &value
+		}
+		rows = append(rows, row)
+	}
+
+	return columns, rows, nil
+}
+
+func readSeedJSON(content []byte) ([]string, []seedRow, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	var objects []map[string]interface{}
+	if err := decoder.Decode(&objects); err != nil {
+		return nil, nil, fmt.Errorf("error parsing json: %w", err)
+	}
+
+	columns := make([]string, 0)
+	seen := make(map[string]bool)
+	rows := make([]seedRow, 0, len(objects))
+	for _, object := range objects {
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		// map iteration order is random, keep the column order stable
+		sort.Strings(names)
+
+		row := make(seedRow, len(object))
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				columns = append(columns, name)
+			}
+
+			switch value := object[name].(type) {
+			case nil:
+				row[name] = nil
+			case string:
+				row[name] = &value
+			case json.Number:
+				s := value.String()
+				row[name] = &s
+			case bool:
+				s := strconv.FormatBool(value)
+				row[name] = &s
+			default:
+				encoded, err := json.Marshal(value)
+				if err != nil {
+					return nil, nil, fmt.Errorf("error encoding value of %s: %w", name, err)
+				}
+				s := string(encoded)
+				row[name] = &s
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return columns, rows, nil
+}
+
+// seedLiteral validates a raw value against the model column and renders it
+// as an SQL literal.
+func seedLiteral(column modelColumn, raw *string) (string, error) {
+	if raw == nil {
+		return "NULL", nil
+	}
+	value := *raw
+
+	if column.IsEnum {
+		for _, allowed := range column.EnumValues {
+			if allowed == value {
+				return quoteLiteral(value), nil
+			}
+		}
+		return "", fmt.Errorf("value %q is not one of %s", value, strings.Join(column.EnumValues, ", "))
+	}
+
+	switch column.GoType {
+	case "int", "int64":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return "", fmt.Errorf("value %q is not an integer", value)
+		}
+		return value, nil
+	case "int16", "int32":
+		bitSize, _ := strconv.Atoi(strings.TrimPrefix(column.GoType, "int"))
+		if _, err := strconv.ParseInt(value, 10, bitSize); err != nil {
+			return "", fmt.Errorf("value %q is not a %d-bit integer", value, bitSize)
+		}
+		return value, nil
+	case "float32", "float64":
+		// NaN and Inf would be taken for column names
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", fmt.Errorf("value %q is not a finite number", value)
+		}
+		return value, nil
+	case "bool":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("value %q is not a boolean", value)
+		}
+		return strings.ToUpper(strconv.FormatBool(b)), nil
+	case "time.Time":
+		if !isTimeValue(value) {
+			return "", fmt.Errorf("value %q is not a timestamp", value)
+		}
+		return quoteLiteral(value), nil
+	case "map[string]interface{}", "json.RawMessage":
+		if !json.Valid([]byte(value)) {
+			return "", fmt.Errorf("value %q is not valid json", value)
+		}
+		return quoteLiteral(value), nil
+	default:
+		return quoteLiteral(value), nil
+	}
+}
+
+func isTimeValue(value string) bool {
+	layouts := []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"}
+	for _, layout := range layouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
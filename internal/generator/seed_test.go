@@ -0,0 +1,350 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/parser"
+	"codegenex/internal/types"
+)
+
+// newTestManager returns a manager that keeps its writes in memory on top of
+// an empty directory holding the given config.
+func newTestManager(t *testing.T, configJSON string) *Manager {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "codegenex.json")
+	err := os.WriteFile(path, []byte(configJSON), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Manager{Config: cfg, Files: files.NewMemory(files.OS)}
+}
+
+// generate runs an entity action with fields written as on the command line.
+func generate(t *testing.T, m *Manager, entityName string, action types.Action, opts types.EntityOptions, args ...string) {
+	t.Helper()
+	fields, err := parser.ParseFields(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.GenerateEntity(entityName, action, fields, opts)
+	if err != nil {
+		t.Fatalf("%s %s: %v", entityName, action, err)
+	}
+}
+
+// rowValues renders seed rows for comparison, NULL for nil values.
+func rowValues(columns []string, rows []seedRow) []string {
+	got := make([]string, 0, len(rows))
+	for _, row := range rows {
+		values := make([]string, 0, len(columns))
+		for _, name := range columns {
+			value, ok := row[name]
+			switch {
+			case !ok:
+				values = append(values, "-")
+			case value == nil:
+				values = append(values, "NULL")
+			default:
+				values = append(values, *value)
+			}
+		}
+		got = append(got, strings.Join(values, "|"))
+	}
+	return got
+}
+
+func TestReadSeedFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		content     string
+		wantColumns []string
+		wantRows    []string
+		wantErr     string
+	}{
+		{
+			name:        "csv",
+			file:        "users.csv",
+			content:     "id, name ,role\n1,Ann,admin\n2,,user\n",
+			wantColumns: []string{"id", "name", "role"},
+			wantRows:    []string{"1|Ann|admin", "2|NULL|user"},
+		},
+		{
+			name:        "csv quoted",
+			file:        "users.CSV",
+			content:     "id,name\n1,\"Smith, John\"\n",
+			wantColumns: []string{"id", "name"},
+			wantRows:    []string{"1|Smith, John"},
+		},
+		{
+			name:    "csv without header",
+			file:    "users.csv",
+			content: "",
+			wantErr: "csv file has no header",
+		},
+		{
+			name:    "csv with a short row",
+			file:    "users.csv",
+			content: "id,name\n1\n",
+			wantErr: "error parsing csv",
+		},
+		{
+			name:        "json",
+			file:        "users.json",
+			content:     `[{"name": "Ann", "id": 1, "active": true, "meta": {"a": [1]}}, {"id": 2.5, "name": null, "note": "x"}]`,
+			wantColumns: []string{"active", "id", "meta", "name", "note"},
+			wantRows:    []string{`true|1|{"a":[1]}|Ann|-`, "-|2.5|-|NULL|x"},
+		},
+		{
+			name:        "json large integer",
+			file:        "users.json",
+			content:     `[{"id": 9007199254740993}]`,
+			wantColumns: []string{"id"},
+			wantRows:    []string{"9007199254740993"},
+		},
+		{
+			name:    "json object",
+			file:    "users.json",
+			content: `{"id": 1}`,
+			wantErr: "error parsing json",
+		},
+		{
+			name:    "unsupported format",
+			file:    "users.yaml",
+			content: "- id: 1\n",
+			wantErr: "unsupported seed file format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			path := filepath.Join(t.TempDir(), tt.file)
+			err := m.Files.WriteFile(path, []byte(tt.content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			columns, rows, err := readSeedFile(path, m.Files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("readSeedFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(columns, tt.wantColumns) {
+				t.Errorf("columns = %q, want %q", columns, tt.wantColumns)
+			}
+			got := rowValues(columns, rows)
+			if !reflect.DeepEqual(got, tt.wantRows) {
+				t.Errorf("rows = %q, want %q", got, tt.wantRows)
+			}
+		})
+	}
+}
+
+func TestSeedLiteral(t *testing.T) {
+	role := modelColumn{Name: "role", GoType: "string", IsEnum: true, EnumValues: []string{"admin", "user"}}
+
+	tests := []struct {
+		name    string
+		column  modelColumn
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "enum", column: role, value: "admin", want: "'admin'"},
+		{name: "enum unknown value", column: role, value: "root", wantErr: `value "root" is not one of admin, user`},
+		{name: "int64", column: modelColumn{GoType: "int64"}, value: "-9223372036854775808", want: "-9223372036854775808"},
+		{name: "int64 out of range", column: modelColumn{GoType: "int64"}, value: "9223372036854775808", wantErr: `value "9223372036854775808" is not an integer`},
+		{name: "int not a number", column: modelColumn{GoType: "int"}, value: "1.5", wantErr: `value "1.5" is not an integer`},
+		{name: "int16", column: modelColumn{GoType: "int16"}, value: "32767", want: "32767"},
+		{name: "int16 out of range", column: modelColumn{GoType: "int16"}, value: "32768", wantErr: `value "32768" is not a 16-bit integer`},
+		{name: "int32 out of range", column: modelColumn{GoType: "int32"}, value: "-2147483649", wantErr: `value "-2147483649" is not a 32-bit integer`},
+		{name: "float", column: modelColumn{GoType: "float64"}, value: "1.5e3", want: "1.5e3"},
+		{name: "float not a number", column: modelColumn{GoType: "float64"}, value: "abc", wantErr: `value "abc" is not a finite number`},
+		{name: "float NaN", column: modelColumn{GoType: "float64"}, value: "NaN", wantErr: `value "NaN" is not a finite number`},
+		{name: "float Inf", column: modelColumn{GoType: "float32"}, value: "-Infinity", wantErr: `value "-Infinity" is not a finite number`},
+		{name: "bool", column: modelColumn{GoType: "bool"}, value: "t", want: "TRUE"},
+		{name: "bool false", column: modelColumn{GoType: "bool"}, value: "false", want: "FALSE"},
+		{name: "bool invalid", column: modelColumn{GoType: "bool"}, value: "yes", wantErr: `value "yes" is not a boolean`},
+		{name: "time", column: modelColumn{GoType: "time.Time"}, value: "2024-01-02", want: "'2024-01-02'"},
+		{name: "time invalid", column: modelColumn{GoType: "time.Time"}, value: "yesterday", wantErr: `value "yesterday" is not a timestamp`},
+		{name: "json", column: modelColumn{GoType: "map[string]interface{}"}, value: `{"a":1}`, want: `'{"a":1}'`},
+		{name: "json invalid", column: modelColumn{GoType: "json.RawMessage"}, value: `{"a"`, wantErr: `value "{\"a\"" is not valid json`},
+		{name: "string quoted", column: modelColumn{GoType: "string"}, value: "O'Brien", want: "'O''Brien'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := seedLiteral(tt.column, &tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("seedLiteral() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("seedLiteral() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	got, err := seedLiteral(modelColumn{GoType: "int64"}, nil)
+	if err != nil || got != "NULL" {
+		t.Errorf("seedLiteral(nil) = %s, %v, want NULL", got, err)
+	}
+}
+
+func TestGenerateSeed(t *testing.T) {
+	tests := []struct {
+		name string
+		// withoutState removes the state file, leaving only the model
+		withoutState bool
+		file         string
+		content      string
+		wantRows     []string
+		wantKeys     []string
+		wantErr      string
+	}{
+		{
+			name:     "keyed on id",
+			file:     "users.csv",
+			content:  "id,name,email,nickname\n1,Ann,ann@example.com,\n2,Bob,bob@example.com,bobby\n",
+			wantRows: []string{"(1, 'Ann', 'ann@example.com', NULL)", "(2, 'Bob', 'bob@example.com', 'bobby')"},
+			wantKeys: []string{"id = 1", "id = 2"},
+		},
+		{
+			name:     "keyed on a unique field",
+			file:     "users.json",
+			content:  `[{"name": "Ann", "email": "ann@example.com"}, {"email": "bob@example.com"}]`,
+			wantRows: []string{"('ann@example.com', 'Ann')", "('bob@example.com', DEFAULT)"},
+			wantKeys: []string{"email = 'ann@example.com'", "email = 'bob@example.com'"},
+		},
+		{
+			name:    "without a key",
+			file:    "users.csv",
+			content: "name,nickname\nAnn,annie\n",
+			wantErr: "seed data needs an id or a unique field to identify its rows in the down migration",
+		},
+		{
+			name:    "unique field missing from a row",
+			file:    "users.json",
+			content: `[{"name": "Ann", "email": "ann@example.com"}, {"name": "Bob"}]`,
+			wantErr: "row 2 has no value of email to identify it in the down migration",
+		},
+		{
+			name:         "unique field without state",
+			withoutState: true,
+			file:         "users.csv",
+			content:      "name,email\nAnn,ann@example.com\n",
+			wantErr:      "seed data needs an id or a unique field to identify its rows in the down migration",
+		},
+		{
+			name:    "NULL in a NOT NULL field",
+			file:    "users.csv",
+			content: "id,name\n1,\n",
+			wantErr: "row 1, field name: value is NULL but the field is NOT NULL",
+		},
+		{
+			name:    "JSON null in a NOT NULL field",
+			file:    "users.json",
+			content: `[{"id": 1, "name": null}]`,
+			wantErr: "row 1, field name: value is NULL but the field is NOT NULL",
+		},
+		{
+			name:         "NULL in a value field without state",
+			withoutState: true,
+			file:         "users.csv",
+			content:      "id,nickname\n1,\n",
+			wantErr:      "row 1, field nickname: value is NULL but the field is NOT NULL",
+		},
+		{
+			name:     "NULL in a pointer field missing from the state",
+			file:     "users.csv",
+			content:  "id,deleted_at\n1,\n",
+			wantRows: []string{"(1, NULL)"},
+			wantKeys: []string{"id = 1"},
+		},
+		{
+			name:    "invalid enum value",
+			file:    "users.csv",
+			content: "id,role\n1,root\n",
+			wantErr: `row 1, field role: value "root" is not one of admin, user`,
+		},
+		{
+			name:    "out of range smallint",
+			file:    "users.csv",
+			content: "id,rank\n1,40000\n",
+			wantErr: `row 1, field rank: value "40000" is not a 16-bit integer`,
+		},
+		{
+			name:    "unknown field",
+			file:    "users.csv",
+			content: "id,age\n1,20\n",
+			wantErr: "unknown field age for entity user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			opts := types.EntityOptions{SoftDelete: true}
+			generate(t, m, "user", types.CreateAction, opts, "name:string", "email:string:unique", "nickname:string:null", "role:enum[admin,user]", "rank:smallint")
+			if tt.withoutState {
+				err := m.Files.Remove(m.Config.StateFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			path := filepath.Join(t.TempDir(), tt.file)
+			err := m.Files.WriteFile(path, []byte(tt.content), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			migration, err := GenerateSeed("user", path, m.Config, m.Files)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("GenerateSeed() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			up, down, err := splitMigration(migration)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range tt.wantRows {
+				if !strings.Contains(up, row) {
+					t.Errorf("up migration lacks row %s:\n%s", row, up)
+				}
+			}
+			for _, key := range tt.wantKeys {
+				if !strings.Contains(down, "DELETE FROM users WHERE "+key+";") {
+					t.Errorf("down migration does not delete by %s:\n%s", key, down)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/template"
+
+	"codegenex/internal/config"
+	"codegenex/internal/ddl"
+	"codegenex/internal/files"
+	"codegenex/internal/lint"
+)
+
+// BaselineData describes the schema a baseline migration creates.
+type BaselineData struct {
+	Enums     []*ddl.Enum
+	Functions []*ddl.Function
+	Tables    []BaselineTable
+	Indexes   []*ddl.Index
+	// ForeignKeys are added once all tables exist, so tables can reference
+	// each other in any order.
+	ForeignKeys []BaselineForeignKey
+	Triggers    []*ddl.Trigger
+	// DropTables lists the tables in reverse dependency order.
+	DropTables []string
+}
+
+type BaselineTable struct {
+	Name        string
+	Definitions []string
+}
+
+type BaselineForeignKey struct {
+	Table      string
+	Constraint *ddl.Constraint
+}
+
+// Squash replaces the migrations up to and including version until with a
+// baseline migration that creates the schema they define. The baseline
+// keeps the version of the last squashed migration, so databases that
+// already applied it skip the baseline. The squashed files are moved to the
+// archive directory.
+func Squash(until int64, cfg *config.Config, fsys files.FS) error {
+	schema, squashed, err := replayMigrations(until, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	for _, migration := range squashed {
+		if changesData(migration) {
+			fmt.Printf("Warning: %s changes data, the baseline only creates the schema\n", migration.File)
+		}
+	}
+
+	tmpl, err := loadTemplate("migrations/baseline.tmpl", template.FuncMap{}, cfg, fsys)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, prepareBaselineData(schema))
+	if err != nil {
+		return fmt.Errorf("error executing migration template: %w", err)
+	}
+
+	err = fsys.MkdirAll(cfg.ArchiveDir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating archive directory: %w", err)
+	}
+	for _, migration := range squashed {
+		archived := filepath.Join(cfg.ArchiveDir, filepath.Base(migration.File))
+		err = fsys.WriteFile(archived, []byte(migration.Content), 0644)
+		if err != nil {
+			return fmt.Errorf("error archiving migration %s: %w", migration.File, err)
+		}
+	}
+
+	fileName := fmt.Sprintf("%d_baseline.sql", until)
+	err = saveMigrationToFile(buf.String(), fileName, cfg, fsys)
+	if err != nil {
+		return fmt.Errorf("error saving migration: %w", err)
+	}
+	fmt.Printf("Migration file generated: %s\n", fileName)
+
+	for _, migration := range squashed {
+		err = fsys.Remove(migration.File)
+		if err != nil {
+			return fmt.Errorf("error removing migration %s: %w", migration.File, err)
+		}
+	}
+	fmt.Printf("%d migrations moved to %s\n", len(squashed), cfg.ArchiveDir)
+	return nil
+}
+
+func prepareBaselineData(schema *ddl.Schema) BaselineData {
+	data := BaselineData{
+		Enums:     schema.Enums,
+		Functions: schema.Functions,
+		Indexes:   schema.Indexes,
+		Triggers:  schema.Triggers,
+	}
+
+	tables := sortTablesByDependency(schema.Tables)
+	for _, table := range tables {
+		definitions := make([]string, 0, len(table.Columns)+len(table.Constraints))
+		for _, column := range table.Columns {
+			definitions = append(definitions, column.Definition())
+		}
+		for _, constraint := range table.Constraints {
+			if constraint.Kind == ddl.ForeignKeyConstraint {
+				continue
+			}
+			definition := constraint.Definition()
+			if constraint.Name != "" {
+				definition = fmt.Sprintf("CONSTRAINT %s %s", constraint.Name, definition)
+			}
+			definitions = append(definitions, definition)
+		}
+		data.Tables = append(data.Tables, BaselineTable{Name: table.Name, Definitions: definitions})
+
+		for _, fk := range table.ForeignKeys() {
+			data.ForeignKeys = append(data.ForeignKeys, BaselineForeignKey{Table: table.Name, Constraint: fk})
+		}
+	}
+
+	for i := len(tables) - 1; i >= 0; i-- {
+		data.DropTables = append(data.DropTables, tables[i].Name)
+	}
+	return data
+}
+
+// changesData reports whether the Up section of the migration inserts,
+// updates or deletes rows.
+func changesData(migration lint.Migration) bool {
+	up, _, _ := lint.Sections(migration.Content)
+	statements, err := ddl.Parse(up)
+	if err != nil {
+		return false
+	}
+	for _, stmt := range statements {
+		if other, ok := stmt.(*ddl.Other); ok {
+			switch other.Keyword {
+			case "insert", "update", "delete", "copy":
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// migration wraps up and down statements in goose annotations.
+func migration(up, down string) string {
+	return "-- +goose Up\n" + up + "\n\n-- +goose Down\n" + down + "\n"
+}
+
+func TestSquash(t *testing.T) {
+	migrations := map[string]string{
+		// comments are created before the users they reference
+		"20240101120000_create_comments.sql": migration(
+			"CREATE TYPE comment_status AS ENUM ('draft', 'published');\n"+
+				"CREATE TABLE comments (id SERIAL PRIMARY KEY, body TEXT NOT NULL, status comment_status NOT NULL, user_id INTEGER NOT NULL);",
+			"DROP TABLE comments;\nDROP TYPE comment_status;"),
+		"20240101120001_create_users.sql": migration(
+			"CREATE TABLE users (id SERIAL PRIMARY KEY, email VARCHAR(255) NOT NULL UNIQUE);",
+			"DROP TABLE users;"),
+		"20240101120002_link_comments.sql": migration(
+			"ALTER TABLE comments ADD CONSTRAINT fk_comments_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;\n"+
+				"CREATE INDEX idx_comments_user_id ON comments (user_id);\n"+
+				"ALTER TABLE users ADD COLUMN name TEXT;",
+			"DROP INDEX idx_comments_user_id;\nALTER TABLE comments DROP CONSTRAINT fk_comments_user_id;\nALTER TABLE users DROP COLUMN name;"),
+		"20240101120003_add_age_to_users.sql": migration(
+			"ALTER TABLE users ADD COLUMN age INTEGER;",
+			"ALTER TABLE users DROP COLUMN age;"),
+	}
+
+	tests := []struct {
+		name  string
+		until int64
+		// want lists statements of the baseline in the order they must appear
+		want        []string
+		wantMissing []string
+		kept        []string
+		wantErr     string
+	}{
+		{
+			name:  "until the foreign key",
+			until: 20240101120002,
+			want: []string{
+				"-- +goose Up",
+				"CREATE TYPE comment_status AS ENUM",
+				"CREATE TABLE users (",
+				"name text",
+				"CREATE TABLE comments (",
+				"CREATE INDEX idx_comments_user_id ON comments (user_id);",
+				"ALTER TABLE comments ADD CONSTRAINT fk_comments_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;",
+				"-- +goose Down",
+				"DROP TABLE IF EXISTS comments;",
+				"DROP TABLE IF EXISTS users;",
+				"DROP TYPE IF EXISTS comment_status;",
+			},
+			wantMissing: []string{"age"},
+			kept:        []string{"20240101120003_add_age_to_users.sql"},
+		},
+		{
+			name:  "all migrations",
+			until: 20240101120003,
+			want:  []string{"CREATE TABLE users (", "age integer", "CREATE TABLE comments ("},
+		},
+		{
+			name:  "before the foreign key",
+			until: 20240101120001,
+			want:  []string{"CREATE TABLE comments (", "CREATE TABLE users ("},
+			wantMissing: []string{
+				"fk_comments_user_id",
+				"idx_comments_user_id",
+			},
+			kept: []string{"20240101120002_link_comments.sql", "20240101120003_add_age_to_users.sql"},
+		},
+		{
+			name:    "unknown version",
+			until:   20240101120005,
+			wantErr: "no migration with version 20240101120005",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			for name, content := range migrations {
+				err := m.Files.WriteFile(filepath.Join(m.Config.MigrationDir, name), []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := m.Squash(tt.until)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("Squash() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the baseline keeps the version of the last squashed migration
+			baseline := fmt.Sprintf("%d_baseline.sql", tt.until)
+			names, err := listMigrations(m.Config, m.Files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wantNames := append([]string{baseline}, tt.kept...)
+			if !reflect.DeepEqual(names, wantNames) {
+				t.Errorf("migrations = %q, want %q", names, wantNames)
+			}
+
+			content, err := m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, baseline))
+			if err != nil {
+				t.Fatal(err)
+			}
+			position := -1
+			for _, want := range tt.want {
+				next := strings.Index(string(content)[position+1:], want)
+				if next < 0 {
+					t.Fatalf("%q missing or out of order in the baseline:\n%s", want, content)
+				}
+				position += next + 1
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(string(content), missing) {
+					t.Errorf("baseline contains %q of a later migration:\n%s", missing, content)
+				}
+			}
+
+			// squashed files move to the archive unchanged
+			archived, err := m.Files.ReadDir(m.Config.ArchiveDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(archived)+len(tt.kept) != len(migrations) {
+				t.Errorf("archived = %q, want the migrations up to %d", archived, tt.until)
+			}
+			for _, name := range archived {
+				got, err := m.Files.ReadFile(filepath.Join(m.Config.ArchiveDir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != migrations[name] {
+					t.Errorf("archived %s = %q, want %q", name, got, migrations[name])
+				}
+			}
+
+			findings, err := m.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, finding := range findings {
+				t.Error(finding)
+			}
+		})
+	}
+}
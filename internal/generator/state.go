@@ -0,0 +1,268 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/ddl"
+	"codegenex/internal/files"
+	"codegenex/internal/lint"
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jinzhu/inflection"
+)
+
+// UpdateState records the result of an action in the state file so that
+// later runs know the full previous definition of every entity.
+func UpdateState(entityName string, fields []types.Field, action types.Action, opts types.EntityOptions, cfg *config.Config, fsys files.FS) error {
+	s, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	modelName := inflection.Singular(strcase.ToCamel(entityName))
+	tableName := inflection.Plural(strcase.ToSnake(entityName))
+
+	switch action {
+	case types.CreateAction:
+		entity := &state.Entity{
+			Name:       modelName,
+			Table:      tableName,
+			PrimaryKey: opts.PrimaryKey,
+			SoftDelete: opts.SoftDelete,
+			Fields:     fields,
+		}
+		for _, field := range fields {
+			if field.IsReference {
+				entity.HasMany = appendUnique(entity.HasMany, referencedModelName(field))
+			}
+		}
+		s.SetEntity(entity)
+	case types.AddFieldsAction, types.RemoveFieldsAction:
+		entity := s.Entity(modelName)
+		if entity == nil {
+			fmt.Printf("Warning: entity %s is missing from %s, run `codegenex state from-models` to rebuild it\n", modelName, cfg.StateFile)
+			return nil
+		}
+		if action == types.RemoveFieldsAction {
+			for _, referencedModel := range detachedModels(modelName, fields, s) {
+				entity.HasMany = removeString(entity.HasMany, referencedModel)
+				if referenced := s.Entity(referencedModel); referenced != nil {
+					referenced.HasMany = removeString(referenced.HasMany, modelName)
+				}
+			}
+			entity.RemoveFields(fields)
+			break
+		}
+		// backfill expressions only apply to the run that adds the columns
+		added := make([]types.Field, len(fields))
+		for i, field := range fields {
+			field.Backfill = ""
+			added[i] = field
+		}
+		entity.AddFields(added)
+		for _, field := range fields {
+			if !field.IsReference {
+				continue
+			}
+			referencedModel := referencedModelName(field)
+			entity.HasMany = appendUnique(entity.HasMany, referencedModel)
+			if referenced := s.Entity(referencedModel); referenced != nil {
+				referenced.HasMany = appendUnique(referenced.HasMany, modelName)
+			}
+		}
+	case types.DropAction:
+		s.RemoveEntity(modelName)
+	default:
+		return nil
+	}
+
+	return s.Save(fsys, cfg.StateFile)
+}
+
+// BuildStateFromModels replaces the state with the entities reconstructed
+// from the model directory, keeping what the models do not show from the
+// previous state.
+func BuildStateFromModels(cfg *config.Config, fsys files.FS) error {
+	entities, err := InspectModelDir(cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	// models do not show the phase of column changes, keep it
+	previous, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	s := &state.State{Entities: entities, Changes: previous.Changes}
+	for _, entity := range entities {
+		entity.PrimaryKey = inspectedPrimaryKey(entity, previous.Entity(entity.Name), cfg)
+	}
+	approximate := make([]string, 0)
+	for _, entity := range entities {
+		approximate = append(approximate, inspectedFields(entity, previous.Entity(entity.Name), s, cfg)...)
+	}
+
+	err = s.Save(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("State file updated: %s (%d entities)\n", cfg.StateFile, len(entities))
+	if len(approximate) > 0 {
+		fmt.Printf("Warning: types of %s are derived from their Go types and may be approximate, check them in %s\n", strings.Join(approximate, ", "), cfg.StateFile)
+	}
+	return nil
+}
+
+// inspectedFields replaces the fields of an entity read from its model with
+// the recorded ones whose Go type still matches, since models show neither
+// the exact column type nor indexes, defaults and foreign key options.
+// Reference fields that are not recorded take the key type of the entity
+// they reference. It returns the remaining fields, as Model.field, whose Go
+// type is shared by several field types.
+func inspectedFields(entity, recorded *state.Entity, s *state.State, cfg *config.Config) []string {
+	approximate := make([]string, 0)
+	for i, field := range entity.Fields {
+		var kept *types.Field
+		if recorded != nil {
+			kept = recorded.Field(field.Name)
+		}
+		if kept != nil && kept.IsEnum == field.IsEnum && getGoType(*kept, cfg) == getGoType(field, cfg) {
+			merged := *kept
+			// nullable columns are only visible as hand-written pointers
+			merged.IsNullable = kept.IsNullable || field.IsNullable
+			if field.IsEnum {
+				merged.EnumValues = field.EnumValues
+			}
+			entity.Fields[i] = merged
+			continue
+		}
+
+		if field.IsReference {
+			if referenced := s.Entity(referencedModelName(field)); referenced != nil {
+				entity.Fields[i].Type = getPrimaryKey(referenced.PrimaryKey).RefType
+			}
+			continue
+		}
+		if !field.IsEnum && ambiguousFieldType(field.Type, cfg) {
+			approximate = append(approximate, entity.Name+"."+field.Name)
+		}
+	}
+	return approximate
+}
+
+// inspectedPrimaryKey returns the primary key strategy of an entity read
+// from its model. The Go type of the ID field does not tell uuid from ulid or
+// serial from bigserial and identity, so the strategy recorded in the state
+// or configured for the table is kept when its Go type matches.
+func inspectedPrimaryKey(entity, recorded *state.Entity, cfg *config.Config) string {
+	goType := getPrimaryKey(entity.PrimaryKey).GoType
+
+	candidates := make([]string, 0, 2)
+	if recorded != nil {
+		candidates = append(candidates, recorded.PrimaryKey)
+	}
+	candidates = append(candidates, cfg.EntityPrimaryKey(entity.Table))
+	for _, name := range candidates {
+		if strategy, ok := primaryKeyStrategies[name]; ok && strategy.GoType == goType {
+			return name
+		}
+	}
+
+	guessed := "serial"
+	if goType == "string" {
+		guessed = "uuid"
+	}
+	fmt.Printf("Warning: primary key strategy of %s guessed as %s from its Go type, set primary_key in %s if it differs\n", entity.Name, guessed, cfg.StateFile)
+	return guessed
+}
+
+// BuildStateFromMigrations replaces the state with the entities defined by
+// replaying the Up sections of the migrations in version order. A non-zero
+// until stops after the migration with that version.
+func BuildStateFromMigrations(until int64, cfg *config.Config, fsys files.FS) error {
+	schema, _, err := replayMigrations(until, cfg, fsys)
+	if err != nil {
+		return err
+	}
+
+	previous, err := state.Load(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	s := &state.State{Changes: previous.Changes}
+	for _, table := range sortTablesByDependency(schema.Tables) {
+		modelName := inflection.Singular(strcase.ToCamel(table.Name))
+		opts := types.EntityOptions{
+			PrimaryKey: primaryKeyFromTable(table),
+			SoftDelete: table.Column("deleted_at") != nil,
+		}
+		s.SetEntity(importedEntity(modelName, table.Name, tableFields(schema, table), opts, nil))
+	}
+
+	err = s.Save(fsys, cfg.StateFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("State file updated: %s (%d entities)\n", cfg.StateFile, len(s.Entities))
+	return nil
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+// replayMigrations applies the Up sections of the migrations in version
+// order and returns the resulting schema with the replayed migrations. A
+// non-zero until stops after the migration with that version. Statements
+// that cannot be applied are reported as warnings.
+func replayMigrations(until int64, cfg *config.Config, fsys files.FS) (*ddl.Schema, []lint.Migration, error) {
+	migrations, err := readMigrations(cfg, fsys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	schema := ddl.NewSchema()
+	replayed := make([]lint.Migration, 0, len(migrations))
+	found := until == 0
+	for _, migration := range migrations {
+		version, ok := migrationVersion(filepath.Base(migration.File))
+		if !ok {
+			continue
+		}
+		if until != 0 && version > until {
+			break
+		}
+		found = found || version == until
+
+		up, _, _ := lint.Sections(migration.Content)
+		statements, err := ddl.Parse(up)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing migration %s: %w", migration.File, err)
+		}
+		for _, stmt := range statements {
+			err = schema.Apply(stmt)
+			if err != nil {
+				fmt.Printf("Warning: %s: %v\n", migration.File, err)
+			}
+		}
+		replayed = append(replayed, migration)
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("no migration with version %d in %s", until, getMigrationDir(cfg))
+	}
+	return schema, replayed, nil
+}
@@ -0,0 +1,210 @@
+package generator
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codegenex/internal/state"
+	"codegenex/internal/types"
+)
+
+func loadTestState(t *testing.T, m *Manager) *state.State {
+	t.Helper()
+	s, err := state.Load(m.Files, m.Config.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestBuildStateFromModelsKeepsPrimaryKey(t *testing.T) {
+	for _, strategy := range []string{"serial", "bigserial", "identity", "uuid", "ulid"} {
+		t.Run(strategy, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			generate(t, m, "org", types.CreateAction, types.EntityOptions{PrimaryKey: strategy}, "name:string")
+
+			err := m.BuildStateFromModels()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entity := loadTestState(t, m).Entity("Org")
+			if entity == nil {
+				t.Fatal("entity Org missing from the state")
+			}
+			if entity.PrimaryKey != strategy {
+				t.Errorf("primary key = %q, want %q", entity.PrimaryKey, strategy)
+			}
+		})
+	}
+}
+
+func TestInspectedPrimaryKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		guessed  string
+		recorded string
+		want     string
+	}{
+		{name: "recorded ulid", guessed: "uuid", recorded: "ulid", want: "ulid"},
+		{name: "recorded bigserial", guessed: "", recorded: "bigserial", want: "bigserial"},
+		{name: "recorded identity", guessed: "", recorded: "identity", want: "identity"},
+		{name: "configured for table", config: `{"entities": {"orgs": {"primary_key": "ulid"}}}`, guessed: "uuid", want: "ulid"},
+		{name: "configured globally", config: `{"primary_key": "bigserial"}`, guessed: "", want: "bigserial"},
+		{name: "recorded type changed", guessed: "", recorded: "uuid", want: "serial"},
+		{name: "guessed uuid", guessed: "uuid", want: "uuid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			if config == "" {
+				config = `{}`
+			}
+			m := newTestManager(t, config)
+
+			entity := &state.Entity{Name: "Org", Table: "orgs", PrimaryKey: tt.guessed}
+			var recorded *state.Entity
+			if tt.recorded != "" {
+				recorded = &state.Entity{Name: "Org", Table: "orgs", PrimaryKey: tt.recorded}
+			}
+
+			got := inspectedPrimaryKey(entity, recorded, m.Config)
+			if got != tt.want {
+				t.Errorf("inspectedPrimaryKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildStateFromModelsReferenceType(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "org", types.CreateAction, types.EntityOptions{PrimaryKey: "ulid"}, "name:string")
+
+	err := m.BuildStateFromModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// references take the column type of the primary key recorded in the state
+	generate(t, m, "member", types.CreateAction, types.EntityOptions{}, "org_id:int:ref")
+	migrations, err := m.Files.ReadDir(m.Config.MigrationDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content []byte
+	for _, name := range migrations {
+		if strings.HasSuffix(name, "_create_member.sql") {
+			content, err = m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !strings.Contains(string(content), "org_id CHAR(26) NOT NULL") {
+		t.Errorf("migration does not reference the ulid key:\n%s", content)
+	}
+}
+
+func TestBuildStateFromModelsKeepsFieldTypes(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "org", types.CreateAction, types.EntityOptions{PrimaryKey: "bigserial"}, "name:string")
+	generate(t, m, "member", types.CreateAction, types.EntityOptions{},
+		"external_id:uuid:unique", "bio:text:null", "views:bigint:default=0", "code:varchar(20):i",
+		"role:enum[admin,user]", "org_id:int:ref")
+	before := loadTestState(t, m).Entity("Member")
+
+	err := m.BuildStateFromModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := loadTestState(t, m).Entity("Member")
+	if after == nil {
+		t.Fatal("entity Member missing from the state")
+	}
+	if len(after.Fields) != len(before.Fields) {
+		t.Fatalf("fields = %+v, want %+v", after.Fields, before.Fields)
+	}
+	for i, field := range after.Fields {
+		if !reflect.DeepEqual(field, before.Fields[i]) {
+			t.Errorf("field %d = %+v, want %+v", i, field, before.Fields[i])
+		}
+	}
+}
+
+func TestBuildStateFromModelsReferenceTypeWithoutState(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "org", types.CreateAction, types.EntityOptions{PrimaryKey: "uuid"}, "name:string")
+	generate(t, m, "member", types.CreateAction, types.EntityOptions{}, "org_id:int:ref")
+	err := m.Files.Remove(m.Config.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.BuildStateFromModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	field := loadTestState(t, m).Entity("Member").Field("org_id")
+	if field == nil || field.Type != "uuid" {
+		t.Errorf("org_id = %+v, want a uuid reference", field)
+	}
+}
+
+func TestBuildStateFromMigrations(t *testing.T) {
+	m := newTestManager(t, `{}`)
+	generate(t, m, "user", types.CreateAction, types.EntityOptions{PrimaryKey: "uuid"}, "email:string:unique", "role:enum[admin,user]")
+	generate(t, m, "post", types.CreateAction, types.EntityOptions{SoftDelete: true}, "title:string", "user_id:uuid:ref")
+	generate(t, m, "post", types.AddFieldsAction, types.EntityOptions{}, "views:int:default=0:i")
+	generated := loadTestState(t, m)
+
+	err := m.BuildStateFromMigrations(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed := loadTestState(t, m)
+	for _, name := range []string{"User", "Post"} {
+		want, got := generated.Entity(name), replayed.Entity(name)
+		if got == nil {
+			t.Fatalf("entity %s missing after replaying the migrations", name)
+		}
+		if got.Table != want.Table || got.PrimaryKey != want.PrimaryKey || got.SoftDelete != want.SoftDelete {
+			t.Errorf("%s = %s %s soft delete %v, want %s %s soft delete %v", name, got.Table, got.PrimaryKey, got.SoftDelete, want.Table, want.PrimaryKey, want.SoftDelete)
+		}
+		for _, wantField := range want.Fields {
+			field := got.Field(wantField.Name)
+			if field == nil {
+				t.Errorf("field %s of %s missing after replaying the migrations", wantField.Name, name)
+				continue
+			}
+			if field.IsNullable != wantField.IsNullable || field.IsUnique != wantField.IsUnique || field.IsIndex != wantField.IsIndex ||
+				field.IsReference != wantField.IsReference || field.IsEnum != wantField.IsEnum || field.DefaultValue != wantField.DefaultValue {
+				t.Errorf("field %s of %s = %+v, want %+v", wantField.Name, name, *field, wantField)
+			}
+		}
+	}
+
+	// --until stops after the given migration
+	migrations, err := listMigrations(m.Config, m.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, _ := migrationVersion(migrations[0])
+	err = m.BuildStateFromMigrations(version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entities := loadTestState(t, m).Entities; len(entities) != 1 || entities[0].Name != "User" {
+		t.Errorf("entities = %v, want User only", entities)
+	}
+
+	err = m.BuildStateFromMigrations(version - 1)
+	if err == nil || !strings.HasPrefix(err.Error(), "no migration with version") {
+		t.Errorf("BuildStateFromMigrations() error = %v, want the version reported missing", err)
+	}
+}
@@ -0,0 +1,52 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"text/template"
+
+	"codegenex"
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+)
+
+// loadTemplate parses a template by its name relative to the templates root,
+// e.g. "migrations/create.tmpl". A file with the same name in the configured
+// template directory takes precedence over the embedded default.
+func loadTemplate(name string, funcMap template.FuncMap, cfg *config.Config, fsys files.FS) (*template.Template, error) {
+	source := "embedded " + name
+	var content []byte
+
+	if cfg.TemplateDir != "" {
+		override := filepath.Join(cfg.TemplateDir, filepath.FromSlash(name))
+		if fsys.Exists(override) {
+			data, err := fsys.ReadFile(override)
+			if err != nil {
+				return nil, fmt.Errorf("error reading template %s: %w", override, err)
+			}
+			source = override
+			content = data
+		}
+	}
+
+	if content == nil {
+		data, err := fs.ReadFile(codegenex.Templates, path.Join("templates", name))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("template %s does not exist: %w", name, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading template %s: %w", source, err)
+		}
+		content = data
+	}
+
+	tmpl, err := template.New(path.Base(name)).Funcs(funcMap).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template %s: %w", source, err)
+	}
+
+	return tmpl, nil
+}
@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"codegenex/internal/types"
+)
+
+func TestLoadTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		overrides map[string]string
+		template  string
+		want      string
+		wantErr   string
+	}{
+		{
+			name:     "embedded",
+			config:   `{}`,
+			template: "migrations/seed.tmpl",
+			want:     "-- +goose Up",
+		},
+		{
+			name:      "override",
+			config:    `{"template_dir": "templates"}`,
+			overrides: map[string]string{"migrations/seed.tmpl": "-- seed of {{.TableName}}"},
+			template:  "migrations/seed.tmpl",
+			want:      "-- seed of users",
+		},
+		{
+			name:      "other files stay embedded",
+			config:    `{"template_dir": "templates"}`,
+			overrides: map[string]string{"migrations/create.tmpl": "-- create"},
+			template:  "migrations/seed.tmpl",
+			want:      "-- +goose Up",
+		},
+		{
+			name:     "template dir without overrides",
+			config:   `{"template_dir": "templates"}`,
+			template: "migrations/seed.tmpl",
+			want:     "-- +goose Up",
+		},
+		{
+			name:     "missing template",
+			config:   `{}`,
+			template: "migrations/missing.tmpl",
+			wantErr:  "template migrations/missing.tmpl does not exist",
+		},
+		{
+			name:      "invalid override",
+			config:    `{"template_dir": "templates"}`,
+			overrides: map[string]string{"migrations/seed.tmpl": "{{.Name"},
+			template:  "migrations/seed.tmpl",
+			wantErr:   filepath.Join("templates", "migrations", "seed.tmpl") + ": template: seed.tmpl:1: unclosed action",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, tt.config)
+			for name, content := range tt.overrides {
+				err := m.Files.WriteFile(filepath.Join(m.Config.TemplateDir, filepath.FromSlash(name)), []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tmpl, err := loadTemplate(tt.template, template.FuncMap{"join": strings.Join}, m.Config, m.Files)
+			if tt.wantErr != "" {
+				// override paths depend on the temporary directory, only their tail is compared
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadTemplate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, SeedData{TableName: "users"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(buf.String(), tt.want) {
+				t.Errorf("rendered template = %q, want it to start with %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateEntityUsesTemplateOverrides(t *testing.T) {
+	m := newTestManager(t, `{"template_dir": "templates"}`)
+	override := filepath.Join(m.Config.TemplateDir, "models", "model.tmpl")
+	err := m.Files.WriteFile(override, []byte("package {{.Package}}\n\n// custom model\ntype {{.Name}} struct{}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generate(t, m, "user", types.CreateAction, types.EntityOptions{}, "name:string")
+
+	model, err := m.Files.ReadFile(getModelFilePath("User", m.Config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(model), "// custom model") {
+		t.Errorf("model does not come from the override:\n%s", model)
+	}
+
+	migrations, err := m.Files.ReadDir(m.Config.MigrationDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migration, err := m.Files.ReadFile(filepath.Join(m.Config.MigrationDir, migrations[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(migration), "CREATE TABLE") {
+		t.Errorf("migration does not come from the embedded template:\n%s", migration)
+	}
+}
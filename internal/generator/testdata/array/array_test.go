@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func roundTrip[T any](t *testing.T, value Array[T]) {
+	t.Helper()
+	text, err := value.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scanned Array[T]
+	err = scanned.Scan(text)
+	if err != nil {
+		t.Fatalf("Scan(%v): %v", text, err)
+	}
+	if !reflect.DeepEqual(scanned, value) {
+		t.Errorf("Scan(Value(%#v)) = %#v, text %v", value, scanned, text)
+	}
+}
+
+func TestArrayRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T)
+	}{
+		{name: "nil", run: func(t *testing.T) { roundTrip[string](t, nil) }},
+		{name: "empty", run: func(t *testing.T) { roundTrip(t, Array[string]{}) }},
+		{name: "strings", run: func(t *testing.T) {
+			roundTrip(t, Array[string]{"a", "b,c", `q"uote`, `back\slash`, "", "NULL", "{x}", " padded "})
+		}},
+		{name: "ints", run: func(t *testing.T) { roundTrip(t, Array[int64]{1, -2, 9223372036854775807}) }},
+		{name: "small ints", run: func(t *testing.T) { roundTrip(t, Array[int16]{1, -32768}) }},
+		{name: "floats", run: func(t *testing.T) { roundTrip(t, Array[float64]{1.5, -0.25, 1e300}) }},
+		{name: "bools", run: func(t *testing.T) { roundTrip(t, Array[bool]{true, false}) }},
+		{name: "bytes", run: func(t *testing.T) { roundTrip(t, Array[[]byte]{{0, 1, 255}, {}}) }},
+		{name: "json", run: func(t *testing.T) { roundTrip(t, Array[json.RawMessage]{json.RawMessage(`{"a":[1,"b"]}`)}) }},
+		{name: "times", run: func(t *testing.T) {
+			roundTrip(t, Array[time.Time]{time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, tt.run)
+	}
+}
+
+func TestArrayScanPostgresText(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Array[string]
+		wantErr bool
+	}{
+		{text: `{}`, want: Array[string]{}},
+		{text: `{a,"b c",NULL,"NULL"}`, want: Array[string]{"a", "b c", "", "NULL"}},
+		{text: `{"say \"hi\"","a\\b"}`, want: Array[string]{`say "hi"`, `a\b`}},
+		{text: `[0:1]={x,y}`, want: Array[string]{"x", "y"}},
+		{text: `{{a,b},{c,d}}`, wantErr: true},
+		{text: `{"open}`, wantErr: true},
+		{text: `a,b`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got Array[string]
+			err := got.Scan([]byte(tt.text))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan(%s) error = %v, want error %v", tt.text, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Scan(%s) = %#v, want %#v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArrayScanPostgresTimes(t *testing.T) {
+	var got Array[time.Time]
+	err := got.Scan(`{"2024-01-02 03:04:05.6+03","2024-01-02 03:04:05",2024-01-02}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Array[time.Time]{
+		time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.FixedZone("", 3*60*60)),
+		time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Errorf("element %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
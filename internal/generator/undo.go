@@ -0,0 +1,102 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"codegenex/internal/config"
+	"codegenex/internal/files"
+	"codegenex/internal/journal"
+)
+
+// RecordRun adds the file changes of a run to the journal.
+func RecordRun(command string, changes []files.Change, cfg *config.Config, fsys files.FS) error {
+	j, err := journal.Load(fsys, cfg.JournalFile)
+	if err != nil {
+		return err
+	}
+	j.Add(command, changes)
+	return j.Save(fsys, cfg.JournalFile)
+}
+
+// Undo reverts the last n runs recorded in the journal, newest first. Files
+// created by a run are removed, modified and deleted ones get their
+// previous content back. Nothing is changed when any file differs from what
+// the run left behind.
+func Undo(n int, cfg *config.Config, fsys files.FS) error {
+	j, err := journal.Load(fsys, cfg.JournalFile)
+	if err != nil {
+		return err
+	}
+	if len(j.Runs) == 0 {
+		fmt.Println("Nothing to undo.")
+		return nil
+	}
+	if n > len(j.Runs) {
+		return fmt.Errorf("cannot undo %d runs, the journal has %d", n, len(j.Runs))
+	}
+
+	// revert in memory first so later runs see the files earlier ones left
+	staged := files.NewMemory(fsys)
+	runs := j.Runs[len(j.Runs)-n:]
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+
+		changed := make([]string, 0)
+		for _, file := range run.Files {
+			if !unchangedSinceRun(file, staged) {
+				changed = append(changed, file.Path)
+			}
+		}
+		if len(changed) > 0 {
+			return fmt.Errorf("files changed since `codegenex %s`: %s", run.Command, strings.Join(changed, ", "))
+		}
+
+		for _, file := range run.Files {
+			if file.Existed {
+				err = staged.WriteFile(file.Path, []byte(file.Before), 0644)
+			} else {
+				err = staged.Remove(file.Path)
+			}
+			if err != nil {
+				return fmt.Errorf("error reverting %s: %w", file.Path, err)
+			}
+		}
+	}
+
+	for _, change := range staged.Changes() {
+		if change.Deleted {
+			err = fsys.Remove(change.Path)
+			if err != nil {
+				return fmt.Errorf("error removing %s: %w", change.Path, err)
+			}
+			fmt.Printf("File removed: %s\n", change.Path)
+			continue
+		}
+		err = fsys.MkdirAll(filepath.Dir(change.Path), 0755)
+		if err != nil {
+			return fmt.Errorf("error creating directory of %s: %w", change.Path, err)
+		}
+		err = fsys.WriteFile(change.Path, change.After, 0644)
+		if err != nil {
+			return fmt.Errorf("error restoring %s: %w", change.Path, err)
+		}
+		fmt.Printf("File restored: %s\n", change.Path)
+	}
+
+	for i := len(runs) - 1; i >= 0; i-- {
+		fmt.Printf("Undone: codegenex %s\n", runs[i].Command)
+	}
+	j.Runs = j.Runs[:len(j.Runs)-n]
+	return j.Save(fsys, cfg.JournalFile)
+}
+
+// unchangedSinceRun reports whether the file is still as the run left it.
+func unchangedSinceRun(file *journal.File, fsys files.FS) bool {
+	content, err := fsys.ReadFile(file.Path)
+	if file.Deleted {
+		return err != nil && !fsys.Exists(file.Path)
+	}
+	return err == nil && journal.Hash(content) == file.Hash
+}
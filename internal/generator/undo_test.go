@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"codegenex/internal/files"
+	"codegenex/internal/types"
+)
+
+// runStaged runs fn the way main does: its writes are staged, recorded in
+// the journal and then applied to disk.
+func runStaged(t *testing.T, disk *Manager, command string, fn func(m *Manager) error) error {
+	t.Helper()
+	staged := files.NewMemory(disk.Files)
+	m := &Manager{Config: disk.Config, Files: staged}
+	err := fn(m)
+	if err != nil {
+		return err
+	}
+	err = m.RecordRun(command, staged.Changes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range staged.Changes() {
+		if change.Deleted {
+			err = disk.Files.Remove(change.Path)
+		} else {
+			err = disk.Files.WriteFile(change.Path, change.After, 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nil
+}
+
+// diskFiles returns the content of the files written to disk, the journal
+// left out.
+func diskFiles(m *Manager) map[string]string {
+	contents := make(map[string]string)
+	for _, change := range m.Files.(*files.Memory).Changes() {
+		if !change.Deleted && change.Path != m.Config.JournalFile {
+			contents[change.Path] = string(change.After)
+		}
+	}
+	return contents
+}
+
+func TestUndo(t *testing.T) {
+	createUser := func(m *Manager) error {
+		fields := []types.Field{{Name: "name", Type: "string"}}
+		return m.GenerateEntity("user", types.CreateAction, fields, types.EntityOptions{})
+	}
+	addEmail := func(m *Manager) error {
+		fields := []types.Field{{Name: "email", Type: "string", IsNullable: true}}
+		return m.GenerateEntity("user", types.AddFieldsAction, fields, types.EntityOptions{})
+	}
+
+	tests := []struct {
+		name    string
+		runs    []func(m *Manager) error
+		edit    bool
+		undo    int
+		kept    int
+		wantErr string
+	}{
+		{name: "last run", runs: []func(m *Manager) error{createUser, addEmail}, undo: 1, kept: 1},
+		{name: "all runs", runs: []func(m *Manager) error{createUser, addEmail}, undo: 2, kept: 0},
+		{name: "edited since", runs: []func(m *Manager) error{createUser}, edit: true, undo: 1, kept: 1, wantErr: "files changed since `codegenex run 1`"},
+		{name: "more than recorded", runs: []func(m *Manager) error{createUser}, undo: 2, kept: 1, wantErr: "cannot undo 2 runs, the journal has 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t, `{}`)
+			snapshots := []map[string]string{diskFiles(m)}
+			for i, run := range tt.runs {
+				err := runStaged(t, m, "run "+strconv.Itoa(i+1), run)
+				if err != nil {
+					t.Fatal(err)
+				}
+				snapshots = append(snapshots, diskFiles(m))
+			}
+			if tt.edit {
+				path := getModelFilePath("User", m.Config)
+				err := m.Files.WriteFile(path, []byte("package models\n"), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				snapshots[tt.kept] = diskFiles(m)
+			}
+
+			err := m.Undo(tt.undo)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("Undo() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			got := diskFiles(m)
+			if !reflect.DeepEqual(got, snapshots[tt.kept]) {
+				t.Errorf("files after undo = %v, want those after %d runs %v", got, tt.kept, snapshots[tt.kept])
+			}
+		})
+	}
+}
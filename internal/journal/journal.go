@@ -0,0 +1,117 @@
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"codegenex/internal/files"
+)
+
+// MaxRuns is the number of runs the journal keeps, older ones can no
+// longer be undone.
+const MaxRuns = 50
+
+// Journal lists the files each run created, modified or deleted, so the
+// runs can be reverted in reverse order.
+type Journal struct {
+	Runs []*Run `json:"runs"`
+}
+
+type Run struct {
+	Command string    `json:"command"`
+	Time    time.Time `json:"time"`
+	Files   []*File   `json:"files"`
+}
+
+// File is a file changed by a run. Before is the content it had before
+// the run and is empty for files the run created; Hash identifies the
+// content the run left, empty for files it deleted.
+type File struct {
+	Path    string `json:"path"`
+	Existed bool   `json:"existed,omitempty"`
+	Before  string `json:"before,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+// Load reads the journal file. A missing file yields an empty journal.
+func Load(fsys files.FS, path string) (*Journal, error) {
+	j := &Journal{Runs: make([]*Run, 0)}
+
+	content, err := fsys.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading journal file %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(content, j)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing journal file %s: %w", path, err)
+	}
+
+	return j, nil
+}
+
+func (j *Journal) Save(fsys files.FS, path string) error {
+	content, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding journal: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		err = fsys.MkdirAll(dir, 0755)
+		if err != nil {
+			return fmt.Errorf("error creating journal directory: %w", err)
+		}
+	}
+
+	err = fsys.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing journal file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Add appends a run with the given file changes and drops the oldest runs
+// beyond MaxRuns. Runs without changes are not recorded.
+func (j *Journal) Add(command string, changes []files.Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	run := &Run{Command: command, Time: time.Now(), Files: make([]*File, 0, len(changes))}
+	for _, change := range changes {
+		file := &File{
+			Path:    change.Path,
+			Existed: change.Existed,
+			Deleted: change.Deleted,
+		}
+		if change.Existed {
+			file.Before = string(change.Before)
+		}
+		if !change.Deleted {
+			file.Hash = Hash(change.After)
+		}
+		run.Files = append(run.Files, file)
+	}
+
+	j.Runs = append(j.Runs, run)
+	if len(j.Runs) > MaxRuns {
+		j.Runs = j.Runs[len(j.Runs)-MaxRuns:]
+	}
+}
+
+// Hash identifies file content in the journal.
+func Hash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
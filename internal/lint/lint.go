@@ -0,0 +1,355 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codegenex/internal/ddl"
+)
+
+type Severity string
+
+const (
+	Off     Severity = "off"
+	Warning Severity = "warning"
+	Error   Severity = "error"
+)
+
+const (
+	RuleSyntax                = "syntax"
+	RuleIndexNotConcurrent    = "index-not-concurrent"
+	RuleNotNullWithoutDefault = "not-null-without-default"
+	RuleTypeChange            = "type-change"
+	RuleDroppedColumn         = "dropped-column-in-model"
+	RuleMissingDown           = "missing-down"
+	RuleConcurrentTransaction = "concurrent-in-transaction"
+)
+
+// DefaultSeverities are used for rules the config does not mention.
+var DefaultSeverities = map[string]Severity{
+	RuleSyntax:                Error,
+	RuleIndexNotConcurrent:    Warning,
+	RuleNotNullWithoutDefault: Error,
+	RuleTypeChange:            Warning,
+	RuleDroppedColumn:         Error,
+	RuleMissingDown:           Warning,
+	RuleConcurrentTransaction: Error,
+}
+
+type Finding struct {
+	File     string
+	Line     int
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s (%s)", f.File, f.Line, f.Severity, f.Message, f.Rule)
+}
+
+// Migration is a goose migration file to check.
+type Migration struct {
+	File    string
+	Content string
+}
+
+type Linter struct {
+	severities map[string]Severity
+	// ModelColumns maps tables to the columns their models still have.
+	ModelColumns map[string][]string
+}
+
+// New returns a linter with the default severities overridden by the given
+// rule to severity map.
+func New(severities map[string]string) (*Linter, error) {
+	l := &Linter{severities: make(map[string]Severity, len(DefaultSeverities))}
+	for rule, severity := range DefaultSeverities {
+		l.severities[rule] = severity
+	}
+	for rule, value := range severities {
+		if _, ok := DefaultSeverities[rule]; !ok {
+			return nil, fmt.Errorf("unknown lint rule %q", rule)
+		}
+		severity := Severity(value)
+		switch severity {
+		case Off, Warning, Error:
+		default:
+			return nil, fmt.Errorf("invalid severity %q of lint rule %s, expected off, warning or error", value, rule)
+		}
+		l.severities[rule] = severity
+	}
+	return l, nil
+}
+
+// HasErrors reports whether any finding has error severity.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+type droppedColumn struct {
+	file   string
+	line   int
+	table  string
+	column string
+}
+
+// Check replays the Up sections of the migrations in order and returns
+// the findings sorted by file and line. Tables created by the same
+// migration are new and empty, so the locking rules skip them.
+func (l *Linter) Check(migrations []Migration) []Finding {
+	findings := make([]Finding, 0)
+	report := func(file string, line int, rule, format string, args ...interface{}) {
+		severity := l.severities[rule]
+		if severity == Off {
+			return
+		}
+		findings = append(findings, Finding{
+			File:     file,
+			Line:     line,
+			Rule:     rule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	schema := ddl.NewSchema()
+	dropped := make([]*droppedColumn, 0)
+
+	for _, migration := range migrations {
+		up, down, downLine := Sections(migration.Content)
+
+		statements, err := ddl.Parse(up)
+		if err != nil {
+			report(migration.File, errorLine(err), RuleSyntax, "%s", errorMessage(err))
+			continue
+		}
+
+		noTransaction := hasAnnotation(migration.Content, "-- +goose NO TRANSACTION")
+
+		created := make(map[string]bool)
+		for _, stmt := range statements {
+			if !noTransaction && isConcurrent(stmt) {
+				report(migration.File, stmt.Line(), RuleConcurrentTransaction,
+					"CONCURRENTLY cannot run inside a transaction, mark the migration with -- +goose NO TRANSACTION")
+			}
+
+			switch stmt := stmt.(type) {
+			case *ddl.CreateTable:
+				created[stmt.Name] = true
+			case *ddl.CreateIndex:
+				if !stmt.Concurrently && !created[stmt.Index.Table] {
+					report(migration.File, stmt.Line(), RuleIndexNotConcurrent,
+						"CREATE INDEX %s locks writes to %s while it is built, use CREATE INDEX CONCURRENTLY", stmt.Index.Name, stmt.Index.Table)
+				}
+			case *ddl.AlterTable:
+				for _, action := range stmt.Actions {
+					switch action.Kind {
+					case ddl.AddColumn:
+						dropped = restoreColumn(dropped, stmt.Table, action.Column.Name)
+						if created[stmt.Table] {
+							continue
+						}
+						if action.Column.NotNull && action.Column.Default == "" && !action.Column.PrimaryKey {
+							report(migration.File, stmt.Line(), RuleNotNullWithoutDefault,
+								"column %s.%s is added as NOT NULL without a default and fails on a table with rows", stmt.Table, action.Column.Name)
+						}
+					case ddl.SetType:
+						if created[stmt.Table] {
+							continue
+						}
+						oldType := ""
+						if table := schema.Table(stmt.Table); table != nil {
+							if column := table.Column(action.ColumnName); column != nil {
+								oldType = column.Type
+							}
+						}
+						if oldType == "" || rewritesTable(oldType, action.Type) {
+							report(migration.File, stmt.Line(), RuleTypeChange,
+								"changing the type of %s.%s to %s rewrites the table under an exclusive lock", stmt.Table, action.ColumnName, action.Type)
+						}
+					case ddl.RenameColumn:
+						dropped = restoreColumn(dropped, stmt.Table, action.NewName)
+					case ddl.DropColumn:
+						dropped = append(dropped, &droppedColumn{
+							file:   migration.File,
+							line:   stmt.Line(),
+							table:  stmt.Table,
+							column: action.ColumnName,
+						})
+					}
+				}
+			}
+			// statements on tables created outside the migrations cannot be
+			// applied and only leave the schema as it is
+			_ = schema.Apply(stmt)
+		}
+
+		if downLine == 0 {
+			report(migration.File, 1, RuleMissingDown, "migration has no -- +goose Down section")
+			continue
+		}
+		downStatements, err := ddl.Parse(down)
+		if err != nil {
+			continue
+		}
+		// a comment explains a Down section that has nothing to undo
+		if len(downStatements) == 0 && !hasComment(down) {
+			report(migration.File, downLine, RuleMissingDown, "-- +goose Down section is empty")
+		}
+		for _, stmt := range downStatements {
+			if !noTransaction && isConcurrent(stmt) {
+				report(migration.File, stmt.Line(), RuleConcurrentTransaction,
+					"CONCURRENTLY cannot run inside a transaction, mark the migration with -- +goose NO TRANSACTION")
+			}
+		}
+	}
+
+	for _, drop := range dropped {
+		for _, column := range l.ModelColumns[drop.table] {
+			if column == drop.column {
+				report(drop.file, drop.line, RuleDroppedColumn,
+					"column %s.%s is dropped but still used by its model", drop.table, drop.column)
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+// isConcurrent reports whether the statement builds or drops an index
+// concurrently.
+func isConcurrent(stmt ddl.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ddl.CreateIndex:
+		return stmt.Concurrently
+	case *ddl.DropIndex:
+		return stmt.Concurrently
+	}
+	return false
+}
+
+func hasAnnotation(content, annotation string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+func hasComment(section string) bool {
+	for _, line := range strings.Split(section, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "--") && !strings.HasPrefix(line, "-- +goose") {
+			return true
+		}
+	}
+	return false
+}
+
+func restoreColumn(dropped []*droppedColumn, table, column string) []*droppedColumn {
+	kept := make([]*droppedColumn, 0, len(dropped))
+	for _, drop := range dropped {
+		if drop.table != table || drop.column != column {
+			kept = append(kept, drop)
+		}
+	}
+	return kept
+}
+
+// Sections splits a goose migration into its Up and Down parts. Lines of
+// the other part are blanked so statement lines match the file. downLine
+// is the line of the Down annotation, 0 when there is none. A file without
+// annotations is taken as a single Up section.
+func Sections(content string) (string, string, int) {
+	lines := strings.Split(content, "\n")
+	up := make([]string, len(lines))
+	down := make([]string, len(lines))
+
+	downLine := 0
+	current := up
+	hasUp := false
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case "-- +goose Up":
+			current = up
+			hasUp = true
+			continue
+		case "-- +goose Down":
+			current = down
+			downLine = i + 1
+			continue
+		}
+		current[i] = line
+	}
+
+	if !hasUp && downLine == 0 {
+		return content, "", 0
+	}
+	return strings.Join(up, "\n"), strings.Join(down, "\n"), downLine
+}
+
+var varcharPattern = regexp.MustCompile(`^varchar(?:\((\d+)\))?$`)
+
+// rewritesTable reports whether changing a column type needs a table
+// rewrite. Widening varchar and changing varchar to text only touch the
+// catalog.
+func rewritesTable(oldType, newType string) bool {
+	if oldType == newType {
+		return false
+	}
+	oldMatch := varcharPattern.FindStringSubmatch(oldType)
+	if oldMatch == nil {
+		return true
+	}
+	if newType == "text" {
+		return false
+	}
+	newMatch := varcharPattern.FindStringSubmatch(newType)
+	if newMatch == nil {
+		return true
+	}
+	if newMatch[1] == "" {
+		return false
+	}
+	if oldMatch[1] == "" {
+		return true
+	}
+	oldLength, _ := strconv.Atoi(oldMatch[1])
+	newLength, _ := strconv.Atoi(newMatch[1])
+	return newLength < oldLength
+}
+
+var errorLinePattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// errorLine extracts the line of a ddl error of the form "line N: msg".
+func errorLine(err error) int {
+	if match := errorLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		line, _ := strconv.Atoi(match[1])
+		return line
+	}
+	return 1
+}
+
+func errorMessage(err error) string {
+	if match := errorLinePattern.FindStringSubmatch(err.Error()); match != nil {
+		return match[2]
+	}
+	return err.Error()
+}
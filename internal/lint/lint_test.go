@@ -0,0 +1,149 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// migration wraps up and down statements in goose annotations.
+func migration(up, down string) string {
+	return "-- +goose Up\n" + up + "\n-- +goose Down\n" + down + "\n"
+}
+
+const createUsers = "CREATE TABLE users (id SERIAL PRIMARY KEY, name VARCHAR(50) NOT NULL);"
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		severities map[string]string
+		models     map[string][]string
+		migrations []string
+		want       []string
+	}{
+		{
+			name:       "clean",
+			migrations: []string{migration(createUsers+"\nCREATE INDEX idx_users_name ON users (name);", "DROP TABLE users;")},
+			want:       []string{},
+		},
+		{
+			name:       "index on existing table",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("CREATE INDEX idx_users_name ON users (name);", "DROP INDEX idx_users_name;")},
+			want:       []string{"2.sql:2: warning index-not-concurrent"},
+		},
+		{
+			name:       "concurrent index in transaction",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("CREATE INDEX CONCURRENTLY idx_users_name ON users (name);", "DROP INDEX CONCURRENTLY idx_users_name;")},
+			want:       []string{"2.sql:2: error concurrent-in-transaction", "2.sql:4: error concurrent-in-transaction"},
+		},
+		{
+			name:       "concurrent index without transaction",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), "-- +goose NO TRANSACTION\n" + migration("CREATE INDEX CONCURRENTLY idx_users_name ON users (name);", "DROP INDEX CONCURRENTLY idx_users_name;")},
+			want:       []string{},
+		},
+		{
+			name:       "not null without default",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("ALTER TABLE users ADD COLUMN age INTEGER NOT NULL;", "ALTER TABLE users DROP COLUMN age;")},
+			want:       []string{"2.sql:2: error not-null-without-default"},
+		},
+		{
+			name:       "not null with default",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("ALTER TABLE users ADD COLUMN age INTEGER NOT NULL DEFAULT 0;", "ALTER TABLE users DROP COLUMN age;")},
+			want:       []string{},
+		},
+		{
+			name: "type changes",
+			migrations: []string{
+				migration(createUsers, "DROP TABLE users;"),
+				migration("ALTER TABLE users ALTER COLUMN name TYPE VARCHAR(100);", "ALTER TABLE users ALTER COLUMN name TYPE VARCHAR(50);"),
+				migration("ALTER TABLE users ALTER COLUMN name TYPE TEXT;", "ALTER TABLE users ALTER COLUMN name TYPE VARCHAR(100);"),
+				migration("ALTER TABLE users ALTER COLUMN id TYPE BIGINT;", "ALTER TABLE users ALTER COLUMN id TYPE INTEGER;"),
+			},
+			want: []string{"4.sql:2: warning type-change"},
+		},
+		{
+			name:       "dropped column still in model",
+			models:     map[string][]string{"users": {"id", "name"}},
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("ALTER TABLE users DROP COLUMN name;", "ALTER TABLE users ADD COLUMN name VARCHAR(50);")},
+			want:       []string{"2.sql:2: error dropped-column-in-model"},
+		},
+		{
+			name:       "dropped column added back",
+			models:     map[string][]string{"users": {"id", "name"}},
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("ALTER TABLE users DROP COLUMN name;\nALTER TABLE users ADD COLUMN name TEXT;", "")},
+			want:       []string{"2.sql:4: warning missing-down"},
+		},
+		{
+			name:       "missing down",
+			migrations: []string{"-- +goose Up\n" + createUsers},
+			want:       []string{"1.sql:1: warning missing-down"},
+		},
+		{
+			name:       "commented empty down",
+			migrations: []string{migration(createUsers, "-- the table is kept")},
+			want:       []string{},
+		},
+		{
+			name:       "syntax",
+			migrations: []string{migration("CREATE TABLE users (name TEXT DEFAULT 'open);", "")},
+			want:       []string{"1.sql:2: error syntax"},
+		},
+		{
+			name:       "rule off",
+			severities: map[string]string{"missing-down": "off", "index-not-concurrent": "error"},
+			migrations: []string{migration(createUsers, ""), migration("CREATE INDEX idx_users_name ON users (name);", "")},
+			want:       []string{"2.sql:2: error index-not-concurrent"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(tt.severities)
+			if err != nil {
+				t.Fatal(err)
+			}
+			l.ModelColumns = tt.models
+
+			migrations := make([]Migration, 0, len(tt.migrations))
+			for i, content := range tt.migrations {
+				migrations = append(migrations, Migration{File: fmt.Sprintf("%d.sql", i+1), Content: content})
+			}
+
+			got := make([]string, 0)
+			for _, finding := range l.Check(migrations) {
+				got = append(got, fmt.Sprintf("%s:%d: %s %s", finding.File, finding.Line, finding.Severity, finding.Rule))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		severities map[string]string
+		wantErr    string
+	}{
+		{name: "defaults"},
+		{name: "override", severities: map[string]string{"type-change": "error"}},
+		{name: "unknown rule", severities: map[string]string{"no-such-rule": "error"}, wantErr: `unknown lint rule "no-such-rule"`},
+		{name: "invalid severity", severities: map[string]string{"type-change": "fatal"}, wantErr: `invalid severity "fatal" of lint rule type-change, expected off, warning or error`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.severities)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("New() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package lint
+
+import (
+	"errors"
+
+	"codegenex/internal/ddl"
+)
+
+const (
+	RuleRoundTrip = "round-trip"
+	RuleApply     = "apply"
+)
+
+// Verify replays the migrations in order. Each migration's Up section is
+// applied to the schema left by the ones before it and its Down section
+// is applied to the result. The schema must then match the one from
+// before the Up section. Statements on tables that no migration creates
+// are skipped, since those tables predate the migrations.
+func Verify(migrations []Migration) []Finding {
+	findings := make([]Finding, 0)
+	report := func(file string, line int, rule, message string) {
+		findings = append(findings, Finding{
+			File:     file,
+			Line:     line,
+			Rule:     rule,
+			Severity: Error,
+			Message:  message,
+		})
+	}
+	apply := func(file string, schema *ddl.Schema, statements []ddl.Statement) {
+		for _, stmt := range statements {
+			err := schema.Apply(stmt)
+			if err != nil && !errors.Is(err, ddl.ErrUnknownTable) {
+				report(file, errorLine(err), RuleApply, errorMessage(err))
+			}
+		}
+	}
+
+	schema := ddl.NewSchema()
+	for _, migration := range migrations {
+		up, down, downLine := Sections(migration.Content)
+
+		upStatements, err := ddl.Parse(up)
+		if err != nil {
+			report(migration.File, errorLine(err), RuleSyntax, errorMessage(err))
+			continue
+		}
+		downStatements, err := ddl.Parse(down)
+		if err != nil {
+			report(migration.File, errorLine(err), RuleSyntax, errorMessage(err))
+			continue
+		}
+
+		before := schema.Clone()
+		apply(migration.File, schema, upStatements)
+		if downLine == 0 {
+			continue
+		}
+
+		reverted := schema.Clone()
+		apply(migration.File, reverted, downStatements)
+		for _, diff := range ddl.Diff(before, reverted) {
+			report(migration.File, downLine, RuleRoundTrip, "after Down "+diff)
+		}
+	}
+
+	return findings
+}
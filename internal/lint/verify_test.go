@@ -0,0 +1,48 @@
+package lint
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []string
+		want       []string
+	}{
+		{
+			name:       "reversible",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("ALTER TABLE users ADD COLUMN age INTEGER;", "ALTER TABLE users DROP COLUMN age;")},
+			want:       []string{},
+		},
+		{
+			name:       "column left over",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("ALTER TABLE users ADD COLUMN age INTEGER;", "")},
+			want:       []string{"2.sql:3: round-trip"},
+		},
+		{
+			name:       "down fails",
+			migrations: []string{migration(createUsers, "DROP TABLE users;"), migration("ALTER TABLE users ADD COLUMN age INTEGER;", "DROP INDEX idx_users_age;")},
+			want:       []string{"2.sql:4: apply", "2.sql:3: round-trip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations := make([]Migration, 0, len(tt.migrations))
+			for i, content := range tt.migrations {
+				migrations = append(migrations, Migration{File: fmt.Sprintf("%d.sql", i+1), Content: content})
+			}
+
+			got := make([]string, 0)
+			for _, finding := range Verify(migrations) {
+				got = append(got, fmt.Sprintf("%s:%d: %s", finding.File, finding.Line, finding.Rule))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Verify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,29 @@ package parser
 
 import (
 	"codegenex/internal/types"
+	"fmt"
 	"strings"
 )
 
+// ParseFlags separates --name and --name=value flags from positional
+// arguments. A flag without a value is stored as "true".
+func ParseFlags(args []string) (map[string]string, []string) {
+	flags := make(map[string]string)
+	positional := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, found := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !found {
+			value = "true"
+		}
+		flags[name] = value
+	}
+	return flags, positional
+}
+
 func ParseAction(action string) types.Action {
 	switch action {
 	case "create":
@@ -15,26 +35,48 @@ func ParseAction(action string) types.Action {
 		return types.RemoveFieldsAction
 	case "drop":
 		return types.DropAction
+	case "seed":
+		return types.SeedAction
+	case "rename_field":
+		return types.RenameFieldAction
+	case "change_type":
+		return types.ChangeTypeAction
 	default:
 		return types.UnknownAction
 	}
 }
 
-func ParseFields(args []string) []types.Field {
+// ParseRename parses the old:new argument of rename_field.
+func ParseRename(arg string) (string, string, error) {
+	oldName, newName, found := strings.Cut(arg, ":")
+	if !found || oldName == "" || newName == "" || strings.Contains(newName, ":") {
+		return "", "", fmt.Errorf("invalid rename %q, expected old_name:new_name", arg)
+	}
+	return oldName, newName, nil
+}
+
+func ParseFields(args []string) ([]types.Field, error) {
 	fields := make([]types.Field, 0, len(args))
 	for _, arg := range args {
-		field := parseField(arg)
+		field, err := parseField(arg)
+		if err != nil {
+			return nil, err
+		}
 		fields = append(fields, field)
 	}
-	return fields
+	return fields, nil
 }
 
-func parseField(arg string) types.Field {
+func parseField(arg string) (types.Field, error) {
 	parts := strings.Split(arg, ":")
 	field := types.Field{Name: parts[0]}
 
 	if len(parts) > 1 {
 		field.Type = parts[1]
+		err := checkArrayType(field.Type)
+		if err != nil {
+			return field, fmt.Errorf("field %s: %w", field.Name, err)
+		}
 		if strings.HasPrefix(field.Type, "enum[") && strings.HasSuffix(field.Type, "]") {
 			field.IsEnum = true
 			enumValues := strings.TrimPrefix(strings.TrimSuffix(field.Type, "]"), "enum[")
@@ -50,6 +92,9 @@ func parseField(arg string) types.Field {
 		switch {
 		case option == "i":
 			field.IsIndex = true
+		case strings.HasPrefix(option, "i="):
+			field.IsIndex = true
+			field.IndexMode = strings.TrimPrefix(option, "i=")
 		case option == "unique":
 			field.IsUnique = true
 		case strings.HasPrefix(option, "ref"):
@@ -64,8 +109,40 @@ func parseField(arg string) types.Field {
 			field.IsNullable = true
 		case strings.HasPrefix(option, "default="):
 			field.DefaultValue = strings.TrimPrefix(option, "default=")
+			// array literals such as {} or {a,b} are string constants in SQL
+			if strings.HasSuffix(field.Type, "[]") && strings.HasPrefix(field.DefaultValue, "{") {
+				field.DefaultValue = "'" + field.DefaultValue + "'"
+			}
+		case strings.HasPrefix(option, "backfill="):
+			// the expression may contain casts, so it takes the rest of
+			// the argument
+			field.Backfill = strings.TrimPrefix(strings.Join(parts[i:], ":"), "backfill=")
+			i = len(parts)
+		}
+	}
+
+	return field, nil
+}
+
+// checkArrayType checks the type[] syntax: one pair of brackets after a
+// scalar type. Enums cannot be arrays.
+func checkArrayType(fieldType string) error {
+	if strings.HasPrefix(fieldType, "enum[") {
+		if strings.HasSuffix(fieldType, "][]") {
+			return fmt.Errorf("arrays of enums are not supported")
 		}
+		return nil
+	}
+	if !strings.Contains(fieldType, "[") && !strings.Contains(fieldType, "]") {
+		return nil
 	}
 
-	return field
+	element := strings.TrimSuffix(fieldType, "[]")
+	if strings.HasSuffix(element, "[]") {
+		return fmt.Errorf("multidimensional array type %q is not supported", fieldType)
+	}
+	if element == fieldType || element == "" || strings.ContainsAny(element, "[]") {
+		return fmt.Errorf("invalid array type %q, expected type[]", fieldType)
+	}
+	return nil
 }
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"codegenex/internal/types"
+	"fmt"
+	"strings"
+)
+
+// ParseBatch reads the arguments following the batch command. Each
+// --entity=name (or --entity name) starts a group that takes the field
+// arguments and the --pk and --soft-delete flags after it. A positional
+// argument before the first group is the path of a spec file.
+func ParseBatch(args []string) (string, []types.EntitySpec, error) {
+	specFile := ""
+	specs := make([]types.EntitySpec, 0)
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") || len(arg) == 2 {
+			switch {
+			case len(specs) > 0:
+				field, err := parseField(arg)
+				if err != nil {
+					return "", nil, err
+				}
+				current := &specs[len(specs)-1]
+				current.Fields = append(current.Fields, field)
+			case specFile == "":
+				specFile = arg
+			default:
+				return "", nil, fmt.Errorf("unexpected argument %q before --entity", arg)
+			}
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		switch name {
+		case "entity":
+			if !hasValue {
+				i++
+				if i == len(args) {
+					return "", nil, fmt.Errorf("--entity requires a name")
+				}
+				value = args[i]
+			}
+			specs = append(specs, types.EntitySpec{Name: value, Fields: make([]types.Field, 0)})
+		case "pk", "soft-delete":
+			if len(specs) == 0 {
+				return "", nil, fmt.Errorf("--%s must follow --entity", name)
+			}
+			current := &specs[len(specs)-1]
+			if name == "pk" {
+				current.Options.PrimaryKey = value
+			} else {
+				current.Options.SoftDelete = !hasValue || value == "true"
+			}
+		}
+		// other flags such as --dry-run apply to the whole run
+	}
+
+	if specFile != "" && len(specs) > 0 {
+		return "", nil, fmt.Errorf("use either a spec file or --entity groups")
+	}
+	return specFile, specs, nil
+}
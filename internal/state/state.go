@@ -0,0 +1,186 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"codegenex/internal/files"
+	"codegenex/internal/types"
+)
+
+// State is the persisted definition of every entity codegenex manages. It
+// is the source of truth for previous field definitions, which cannot be
+// recovered from migrations or models alone.
+type State struct {
+	Entities []*Entity `json:"entities"`
+	Changes  []*Change `json:"changes,omitempty"`
+}
+
+type Entity struct {
+	Name       string        `json:"name"`
+	Table      string        `json:"table"`
+	PrimaryKey string        `json:"primary_key,omitempty"`
+	SoftDelete bool          `json:"soft_delete,omitempty"`
+	Fields     []types.Field `json:"fields"`
+	HasMany    []string      `json:"has_many,omitempty"`
+}
+
+// Change is a column change rolled out in phases: the expand phase adds
+// NewColumn next to Column and keeps both in sync, the contract phase drops
+// Column once no running code uses it.
+type Change struct {
+	Entity    string `json:"entity"`
+	Kind      string `json:"kind"`
+	Column    string `json:"column"`
+	NewColumn string `json:"new_column"`
+	// Type is the new field type of a type change.
+	Type  string `json:"type,omitempty"`
+	Phase string `json:"phase"`
+}
+
+const (
+	PhaseExpand   = "expand"
+	PhaseContract = "contract"
+)
+
+// Load reads the state file. A missing file yields an empty state.
+func Load(fsys files.FS, path string) (*State, error) {
+	s := &State{Entities: make([]*Entity, 0)}
+
+	content, err := fsys.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(content, s)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing state file %s: %w", path, err)
+	}
+
+	return s, nil
+}
+
+func (s *State) Save(fsys files.FS, path string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding state: %w", err)
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		err = fsys.MkdirAll(dir, 0755)
+		if err != nil {
+			return fmt.Errorf("error creating state directory: %w", err)
+		}
+	}
+
+	err = fsys.WriteFile(path, append(content, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing state file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (s *State) Entity(name string) *Entity {
+	for _, entity := range s.Entities {
+		if entity.Name == name {
+			return entity
+		}
+	}
+	return nil
+}
+
+// SetEntity adds the entity or replaces the one with the same name.
+func (s *State) SetEntity(entity *Entity) {
+	for i, existing := range s.Entities {
+		if existing.Name == entity.Name {
+			s.Entities[i] = entity
+			return
+		}
+	}
+	s.Entities = append(s.Entities, entity)
+}
+
+// RemoveEntity removes the entity together with its pending changes and
+// the has-many relations of other entities to it.
+func (s *State) RemoveEntity(name string) {
+	entities := make([]*Entity, 0, len(s.Entities))
+	for _, entity := range s.Entities {
+		if entity.Name == name {
+			continue
+		}
+		hasMany := make([]string, 0, len(entity.HasMany))
+		for _, related := range entity.HasMany {
+			if related != name {
+				hasMany = append(hasMany, related)
+			}
+		}
+		if len(hasMany) == 0 {
+			hasMany = nil
+		}
+		entity.HasMany = hasMany
+		entities = append(entities, entity)
+	}
+	s.Entities = entities
+
+	changes := make([]*Change, 0, len(s.Changes))
+	for _, change := range s.Changes {
+		if change.Entity != name {
+			changes = append(changes, change)
+		}
+	}
+	s.Changes = changes
+}
+
+func (e *Entity) Field(name string) *types.Field {
+	for i := range e.Fields {
+		if e.Fields[i].Name == name {
+			return &e.Fields[i]
+		}
+	}
+	return nil
+}
+
+// AddFields appends the fields, replacing existing fields with the same name.
+func (e *Entity) AddFields(fields []types.Field) {
+	for _, field := range fields {
+		if existing := e.Field(field.Name); existing != nil {
+			*existing = field
+			continue
+		}
+		e.Fields = append(e.Fields, field)
+	}
+}
+
+func (e *Entity) RemoveFields(fields []types.Field) {
+	remove := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		remove[field.Name] = true
+	}
+
+	kept := make([]types.Field, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		if !remove[field.Name] {
+			kept = append(kept, field)
+		}
+	}
+	e.Fields = kept
+}
+
+// PendingChanges returns the changes of the entity that still wait for
+// their contract phase, or those of all entities when entity is empty.
+func (s *State) PendingChanges(entity string) []*Change {
+	pending := make([]*Change, 0)
+	for _, change := range s.Changes {
+		if change.Phase == PhaseExpand && (entity == "" || change.Entity == entity) {
+			pending = append(pending, change)
+		}
+	}
+	return pending
+}
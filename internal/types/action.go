@@ -7,6 +7,9 @@ const (
 	AddFieldsAction    Action = "add_fields"
 	RemoveFieldsAction Action = "remove_fields"
 	DropAction         Action = "drop"
+	SeedAction         Action = "seed"
+	RenameFieldAction  Action = "rename_field"
+	ChangeTypeAction   Action = "change_type"
 	UnknownAction      Action = "unknown"
 )
 
@@ -20,6 +23,12 @@ func (a Action) String() string {
 		return "remove_fields"
 	case DropAction:
 		return "drop"
+	case SeedAction:
+		return "seed"
+	case RenameFieldAction:
+		return "rename_field"
+	case ChangeTypeAction:
+		return "change_type"
 	default:
 		return "unknown"
 	}
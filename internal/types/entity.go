@@ -0,0 +1,15 @@
+package types
+
+// EntityOptions are per-entity settings given on the command line. Empty
+// values are filled in from the state and the config.
+type EntityOptions struct {
+	PrimaryKey string
+	SoftDelete bool
+}
+
+// EntitySpec describes one entity of a batch run.
+type EntitySpec struct {
+	Name    string
+	Fields  []Field
+	Options EntityOptions
+}
@@ -1,15 +1,31 @@
 package types
 
 type Field struct {
-	Name            string
-	Type            string
-	IsIndex         bool
-	IsReference     bool
-	RefOptions      string
-	IsNullable      bool
-	DefaultValue    string
-	ReferencedModel string
-	IsEnum          bool
-	EnumValues      []string
-	IsUnique        bool
+	Name            string   `json:"name"`
+	Type            string   `json:"type"`
+	IsIndex         bool     `json:"is_index,omitempty"`
+	IndexMode       string   `json:"index_mode,omitempty"`
+	IsReference     bool     `json:"is_reference,omitempty"`
+	RefOptions      string   `json:"ref_options,omitempty"`
+	IsNullable      bool     `json:"is_nullable,omitempty"`
+	DefaultValue    string   `json:"default_value,omitempty"`
+	ReferencedModel string   `json:"referenced_model,omitempty"`
+	IsEnum          bool     `json:"is_enum,omitempty"`
+	EnumValues      []string `json:"enum_values,omitempty"`
+	IsUnique        bool     `json:"is_unique,omitempty"`
+	Backfill        string   `json:"backfill,omitempty"`
+	// EnumType is the name of an imported enum type. Enums created by
+	// codegenex are named after the table and the field instead.
+	EnumType string `json:"enum_type,omitempty"`
+	// UniqueIndex is set for unique fields covered by a uniq_ index, as
+	// the columns of phased changes are, rather than by the inline
+	// <table>_<field>_key constraint.
+	UniqueIndex bool `json:"unique_index,omitempty"`
 }
+
+// Index modes of the i option. Concurrent indexes on existing tables are
+// built in a separate migration outside a transaction.
+const (
+	IndexConcurrent = "concurrent"
+	IndexBlocking   = "blocking"
+)
@@ -0,0 +1,8 @@
+// Package codegenex embeds the default templates into the binary so it works
+// outside of this repository.
+package codegenex
+
+import "embed"
+
+//go:embed templates
+var Templates embed.FS